@@ -1,17 +1,21 @@
 package app
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/aronipurwanto/go-download-csv/internal/config"
 	httpdeliver "github.com/aronipurwanto/go-download-csv/internal/deliveries/http"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/export"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
 	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
 	"github.com/aronipurwanto/go-download-csv/internal/middleware"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover" // <-- tambahkan ini
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,30 +27,59 @@ func Run() error {
 	if err != nil {
 		return err
 	}
-	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{})
+	// TranslateError: unique violation => gorm.ErrDuplicatedKey (409 di API)
+	db, err := gorm.Open(postgres.Open(cfg.DB.DSN()), &gorm.Config{TranslateError: true})
 
-	// Auto-migrate
-	if err := db.AutoMigrate(&transaction.Transaction{}); err != nil {
+	// Auto-migrate (+ DDL tambahan: full-text search, index)
+	if err := transaction.Migrate(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&export.Job{}, &idempotency.Record{}); err != nil {
 		return err
 	}
 
 	// Repositories & services
 	repo := transaction.NewGormRepository(db)
-	service := transaction.NewService(repo)
+	service := transaction.NewService(repo, transaction.Config{PurgeRetention: cfg.Admin.PurgeRetention})
+
+	// Export jobs (worker pool + file di disk lokal)
+	storage, err := export.NewLocalStorage(cfg.Export.Dir)
+	if err != nil {
+		return err
+	}
+	jobs := export.NewService(export.NewGormRepository(db), storage, service, httpdeliver.NewExportRenderer(service), export.Config{
+		Workers:           cfg.Export.Workers,
+		QueueSize:         cfg.Export.QueueSize,
+		JobTimeout:        cfg.Export.JobTimeout,
+		PollInterval:      cfg.Export.PollInterval,
+		Retention:         cfg.Export.Retention,
+		HeartbeatInterval: cfg.Export.Heartbeat,
+	})
+	jobs.Start(context.Background())
+
+	// Idempotency-Key (retry POST/PUT/DELETE dari client)
+	idem := idempotency.NewService(idempotency.NewGormRepository(db), idempotency.Config{
+		TTL:         cfg.Idempotency.TTL,
+		LockTimeout: cfg.Idempotency.LockTimeout,
+	})
 
 	// Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "transaction-api",
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		BodyLimit:    cfg.Server.BodyLimit,
 	})
 
+	app.Server().HeaderReceived = httpdeliver.UploadRequestConfig(cfg.Server.ImportBodyLimit, cfg.Server.ImportReadTimeout)
+
 	app.Use(recover.New()) // OK setelah import recover
 	app.Use(cors.New())
+	app.Use(requestid.New()) // X-Request-ID, dicatat di transaction_history
 	app.Use(middleware.EnforceResponseEnvelope())
 
 	// Router (pakai alias httpdeliver)
-	httpdeliver.RegisterRoutes(app, service)
+	httpdeliver.RegisterRoutes(app, service, jobs, idem, cfg.Admin.Token)
 
 	log.Println("listening on :8080")
 	return app.Listen(":8080")
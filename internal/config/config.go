@@ -14,12 +14,20 @@ type Config struct {
 	AppName string
 	Server  ServerConfig
 	DB      DatabaseConfig
+	Export  ExportConfig
+
+	Idempotency IdempotencyConfig
+	Admin       AdminConfig
 }
 
 // ServerConfig untuk konfigurasi web server Fiber.
 type ServerConfig struct {
-	Host string
-	Port int
+	Host      string
+	Port      int
+	BodyLimit int // byte; bulk create butuh lebih dari default Fiber (4MB)
+	// POST /v1/transactions/import saja: upload CSV ratusan ribu baris
+	ImportBodyLimit   int // byte
+	ImportReadTimeout time.Duration
 }
 
 // DatabaseConfig menyimpan konfigurasi database PostgreSQL.
@@ -36,6 +44,29 @@ type DatabaseConfig struct {
 	ConnMaxLifetime time.Duration
 }
 
+// ExportConfig untuk worker export asinkron.
+type ExportConfig struct {
+	Dir          string
+	Workers      int
+	QueueSize    int
+	JobTimeout   time.Duration
+	PollInterval time.Duration
+	Retention    time.Duration // file hasil export dihapus setelah ini
+	Heartbeat    time.Duration
+}
+
+// IdempotencyConfig untuk header Idempotency-Key.
+type IdempotencyConfig struct {
+	TTL         time.Duration
+	LockTimeout time.Duration
+}
+
+// AdminConfig untuk operasi admin (purge transaksi dari trash).
+type AdminConfig struct {
+	Token          string // kosong => endpoint admin dinonaktifkan
+	PurgeRetention time.Duration
+}
+
 // LoadConfig membaca konfigurasi dari environment (menggunakan viper).
 func LoadConfig() (*Config, error) {
 	v := viper.New()
@@ -44,8 +75,12 @@ func LoadConfig() (*Config, error) {
 	cfg := &Config{
 		AppName: getEnv("APP_NAME", "transaction-api"),
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-			Port: getEnvInt("SERVER_PORT", 8080),
+			Host:      getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:      getEnvInt("SERVER_PORT", 8080),
+			BodyLimit: getEnvInt("SERVER_BODY_LIMIT_MB", 16) * 1024 * 1024,
+
+			ImportBodyLimit:   getEnvInt("SERVER_IMPORT_BODY_LIMIT_MB", 256) * 1024 * 1024,
+			ImportReadTimeout: getEnvDuration("SERVER_IMPORT_READ_TIMEOUT", 5*time.Minute),
 		},
 		DB: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "localhost"),
@@ -59,6 +94,23 @@ func LoadConfig() (*Config, error) {
 			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", time.Hour),
 		},
+		Export: ExportConfig{
+			Dir:          getEnv("EXPORT_DIR", "./data/exports"),
+			Workers:      getEnvInt("EXPORT_WORKERS", 2),
+			QueueSize:    getEnvInt("EXPORT_QUEUE_SIZE", 100),
+			JobTimeout:   getEnvDuration("EXPORT_JOB_TIMEOUT", time.Hour),
+			PollInterval: getEnvDuration("EXPORT_POLL_INTERVAL", 5*time.Second),
+			Retention:    getEnvDuration("EXPORT_RETENTION", 7*24*time.Hour),
+			Heartbeat:    getEnvDuration("EXPORT_HEARTBEAT_INTERVAL", 30*time.Second),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:         getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			LockTimeout: getEnvDuration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+		},
+		Admin: AdminConfig{
+			Token:          getEnv("ADMIN_TOKEN", ""),
+			PurgeRetention: getEnvDuration("PURGE_RETENTION", 30*24*time.Hour),
+		},
 	}
 	return cfg, nil
 }
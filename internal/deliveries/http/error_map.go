@@ -0,0 +1,49 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/export"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorStatus memetakan error domain ke status HTTP; error lain => fallback
+// (biasanya 400 untuk validasi input, 500 untuk kegagalan storage).
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, transaction.ErrNotFound), errors.Is(err, export.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, transaction.ErrInvalidTransition), errors.Is(err, transaction.ErrDuplicate),
+		errors.Is(err, export.ErrNotReady):
+		return fiber.StatusConflict
+	case errors.Is(err, transaction.ErrInvalidCursor):
+		return fiber.StatusBadRequest
+	case errors.Is(err, transaction.ErrNotDeleted), errors.Is(err, transaction.ErrRetention),
+		errors.Is(err, transaction.ErrHasChildren):
+		return fiber.StatusConflict
+	case errors.Is(err, transaction.ErrVersionConflict):
+		return fiber.StatusPreconditionFailed
+	case errors.Is(err, transaction.ErrRefundExceeded), errors.Is(err, transaction.ErrReadOnlyField),
+		errors.Is(err, transaction.ErrLinkedAmount):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, export.ErrExpired):
+		return fiber.StatusGone
+	default:
+		return fallback
+	}
+}
+
+// respondError: response error standar; duplikat menyertakan transaksi yang
+// bentrok di data.
+func respondError(c *fiber.Ctx, err error, fallback int) error {
+	var dup *transaction.DuplicateError
+	if errors.As(err, &dup) {
+		return response.ErrorWithData(c, fiber.StatusConflict, err.Error(), fiber.Map{
+			"field":                      dup.Field,
+			"conflicting_transaction_id": dup.ConflictID,
+		})
+	}
+	return response.Error(c, errorStatus(err, fallback), err.Error())
+}
@@ -0,0 +1,236 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- Kolom export (CSV & XLSX)
+//
+// Satu definisi kolom dipakai untuk header dan isi baris, jadi keduanya tidak
+// bisa bergeser. Kolom dipilih lewat ?columns=key[:Label],... atau ?preset=.
+
+type columnKind int
+
+const (
+	colText   columnKind = iota
+	colTime              // sel tanggal di XLSX, RFC3339 di CSV
+	colAmount            // sel angka + format mata uang di XLSX
+)
+
+type exportColumn struct {
+	Key   string // nama field JSON di transaction.Response
+	Label string
+	Kind  columnKind
+	text  func(transaction.Response) string
+	time  func(transaction.Response) time.Time // hanya untuk colTime
+}
+
+func textColumn(key, label string, get func(transaction.Response) string) exportColumn {
+	return exportColumn{Key: key, Label: label, Kind: colText, text: get}
+}
+
+func timeColumn(key, label string, get func(transaction.Response) time.Time) exportColumn {
+	return exportColumn{Key: key, Label: label, Kind: colTime, time: get, text: func(it transaction.Response) string {
+		return get(it).Format(time.RFC3339)
+	}}
+}
+
+// nullableTimeColumn: nil => sel kosong di CSV & XLSX.
+func nullableTimeColumn(key, label string, get func(transaction.Response) *time.Time) exportColumn {
+	col := timeColumn(key, label, func(it transaction.Response) time.Time {
+		if t := get(it); t != nil {
+			return *t
+		}
+		return time.Time{}
+	})
+	col.text = func(it transaction.Response) string {
+		if t := get(it); t != nil {
+			return t.Format(time.RFC3339)
+		}
+		return ""
+	}
+	return col
+}
+
+// exportColumnDefs: semua field Response yang bisa di-export, urutan default.
+var exportColumnDefs = []exportColumn{
+	textColumn("transaction_id", "Transaction ID", func(it transaction.Response) string { return it.TransactionID }),
+	textColumn("no_ref", "No Ref", func(it transaction.Response) string { return it.NoRef }),
+	textColumn("order_type_code", "Order Type Code", func(it transaction.Response) string { return it.OrderTypeCode }),
+	textColumn("order_type_name", "Order Type Name", func(it transaction.Response) string { return it.OrderTypeName }),
+	textColumn("transaction_type_code", "Transaction Type Code", func(it transaction.Response) string { return it.TransactionTypeCode }),
+	textColumn("transaction_type_name", "Transaction Type Name", func(it transaction.Response) string { return it.TransactionTypeName }),
+	timeColumn("transaction_date", "Transaction Date", func(it transaction.Response) time.Time { return it.TransactionDate }),
+	textColumn("from_account_number", "From Account Number", func(it transaction.Response) string { return it.FromAccountNumber }),
+	textColumn("from_account_name", "From Account Name", func(it transaction.Response) string { return it.FromAccountName }),
+	textColumn("from_account_product_name", "From Account Product Name", func(it transaction.Response) string { return it.FromAccountProductName }),
+	textColumn("to_account_number", "To Account Number", func(it transaction.Response) string { return it.ToAccountNumber }),
+	textColumn("to_account_name", "To Account Name", func(it transaction.Response) string { return it.ToAccountName }),
+	textColumn("to_account_product_name", "To Account Product Name", func(it transaction.Response) string { return it.ToAccountProductName }),
+	{Key: "amount", Label: "Amount", Kind: colAmount, text: func(it transaction.Response) string {
+		return transaction.FormatAmount(it.Amount, it.Currency)
+	}},
+	textColumn("status", "Status", func(it transaction.Response) string { return it.Status }),
+	textColumn("status_reason", "Status Reason", func(it transaction.Response) string { return it.StatusReason }),
+	nullableTimeColumn("status_changed_at", "Status Changed At", func(it transaction.Response) *time.Time { return it.StatusChangedAt }),
+	textColumn("parent_transaction_id", "Parent Transaction ID", func(it transaction.Response) string { return it.ParentTransactionID }),
+	textColumn("kind", "Kind", func(it transaction.Response) string { return it.Kind }),
+	textColumn("description", "Description", func(it transaction.Response) string { return it.Description }),
+	textColumn("method", "Method", func(it transaction.Response) string { return it.Method }),
+	textColumn("currency", "Currency", func(it transaction.Response) string { return it.Currency }),
+	textColumn("metadata", "Metadata", func(it transaction.Response) string { return string(it.Metadata) }),
+	timeColumn("created_at", "Created At", func(it transaction.Response) time.Time { return it.CreatedAt }),
+	timeColumn("updated_at", "Updated At", func(it transaction.Response) time.Time { return it.UpdatedAt }),
+	textColumn("version", "Version", func(it transaction.Response) string { return strconv.FormatInt(it.Version, 10) }),
+	// export tidak memuat baris trash: hanya terisi untuk baris yang dihapus
+	// setelah snapshot manifest dibuat
+	nullableTimeColumn("deleted_at", "Deleted At", func(it transaction.Response) *time.Time { return it.DeletedAt }),
+}
+
+// preset kolom (?preset=). default = kolom export lama (tanpa created/updated).
+var exportColumnPresets = map[string][]string{
+	"default": {
+		"transaction_id", "no_ref", "order_type_code", "order_type_name",
+		"transaction_type_code", "transaction_type_name", "transaction_date",
+		"from_account_number", "from_account_name", "from_account_product_name",
+		"to_account_number", "to_account_name", "to_account_product_name",
+		"amount", "status", "description", "method", "currency", "metadata",
+	},
+	"ops": {"transaction_id", "transaction_date", "amount", "status"},
+	"audit": {
+		"transaction_id", "no_ref", "order_type_code", "order_type_name",
+		"transaction_type_code", "transaction_type_name", "transaction_date",
+		"from_account_number", "from_account_name", "from_account_product_name",
+		"to_account_number", "to_account_name", "to_account_product_name",
+		"amount", "status", "status_reason", "status_changed_at", "parent_transaction_id", "kind",
+		"description", "method", "currency", "metadata",
+		"created_at", "updated_at", "version",
+	},
+}
+
+const maxColumnLabel = 100
+
+func lookupExportColumn(key string) (exportColumn, bool) {
+	for _, col := range exportColumnDefs {
+		if col.Key == key {
+			return col, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// rejectColumnParams untuk format dengan skema tetap (ndjson, parquet).
+func rejectColumnParams(c *fiber.Ctx, ext string) error {
+	if c.Query("columns") != "" || c.Query("preset") != "" {
+		return fmt.Errorf("columns/preset is not supported for %s", ext)
+	}
+	return nil
+}
+
+// exportColumns adalah kolom terpilih, urut sesuai file.
+type exportColumns []exportColumn
+
+func defaultExportColumns() exportColumns {
+	cols, _ := parseExportColumns("default", "")
+	return cols
+}
+
+// parseExportColumns: preset (nama) atau spec "key[:Label],key2,...".
+// Keduanya kosong => preset default.
+func parseExportColumns(preset, spec string) (exportColumns, error) {
+	preset, spec = strings.TrimSpace(preset), strings.TrimSpace(spec)
+	if preset != "" && spec != "" {
+		return nil, fmt.Errorf("use either columns or preset, not both")
+	}
+	if spec == "" {
+		if preset == "" {
+			preset = "default"
+		}
+		keys, ok := exportColumnPresets[preset]
+		if !ok {
+			return nil, fmt.Errorf("invalid preset %q (default|ops|audit)", preset)
+		}
+		spec = strings.Join(keys, ",")
+	}
+
+	var cols exportColumns
+	seen := map[string]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		key, label, hasLabel := strings.Cut(item, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		col, ok := lookupExportColumn(key)
+		if !ok {
+			return nil, fmt.Errorf("invalid column %q", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate column %q", key)
+		}
+		seen[key] = true
+		if hasLabel {
+			label = strings.TrimSpace(label)
+			if label == "" || len(label) > maxColumnLabel {
+				return nil, fmt.Errorf("invalid label for column %q (1-%d chars)", key, maxColumnLabel)
+			}
+			col.Label = label
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// spec mengembalikan bentuk ?columns= yang setara (untuk link manifest &
+// job), kosong jika sama dengan default.
+func (cs exportColumns) spec() string {
+	def := defaultExportColumns()
+	items := make([]string, len(cs))
+	same := len(cs) == len(def)
+	for i, col := range cs {
+		items[i] = col.Key
+		if base, _ := lookupExportColumn(col.Key); col.Label != base.Label {
+			items[i] += ":" + col.Label
+		}
+		if same && (col.Key != def[i].Key || col.Label != def[i].Label) {
+			same = false
+		}
+	}
+	if same {
+		return ""
+	}
+	return strings.Join(items, ",")
+}
+
+func (cs exportColumns) labels() []string {
+	out := make([]string, len(cs))
+	for i, col := range cs {
+		out[i] = col.Label
+	}
+	return out
+}
+
+func (cs exportColumns) writeCSVHeader(w *csv.Writer) error {
+	return w.Write(cs.labels())
+}
+
+func (cs exportColumns) writeCSVRow(w *csv.Writer, it transaction.Response) error {
+	row := make([]string, len(cs))
+	for i, col := range cs {
+		row[i] = col.text(it)
+	}
+	return w.Write(row)
+}
+
+func (cs exportColumns) csvHeaderBytes() int {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = cs.writeCSVHeader(w)
+	w.Flush()
+	return buf.Len()
+}
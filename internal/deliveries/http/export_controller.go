@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/export"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
+	"github.com/aronipurwanto/go-download-csv/internal/middleware"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+const createExportLocalKey = "create_export_body"
+
+type ExportController struct {
+	svc        export.Service
+	timeout    time.Duration
+	idempotent fiber.Handler
+}
+
+func NewExportController(svc export.Service) *ExportController {
+	return &ExportController{svc: svc, timeout: defaultTimeout, idempotent: passThrough}
+}
+
+// WithIdempotency mengaktifkan header Idempotency-Key untuk POST /exports.
+func (h *ExportController) WithIdempotency(svc idempotency.Service) *ExportController {
+	h.idempotent = middleware.Idempotency(svc, h.timeout)
+	return h
+}
+
+func (h *ExportController) withCtx(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Context(), h.timeout)
+}
+
+func RegisterExportRoutes(r fiber.Router, svc export.Service) {
+	NewExportController(svc).Register(r)
+}
+
+func (h *ExportController) Register(r fiber.Router) {
+	g := r.Group("/exports")
+
+	// POST /v1/exports
+	g.Post("/",
+		h.idempotent,
+		middleware.ValidateBody[export.CreateRequest](export.ValidateCreate, createExportLocalKey),
+		h.create,
+	)
+
+	// GET /v1/exports/:id
+	g.Get("/:id", h.getByID)
+
+	// GET /v1/exports/:id/download
+	g.Get("/:id/download", h.download)
+}
+
+func (h *ExportController) create(c *fiber.Ctx) error {
+	req := c.Locals(createExportLocalKey).(export.CreateRequest)
+	// kolom divalidasi di sini supaya job tidak gagal belakangan di worker
+	if req.Options.Columns != "" || req.Options.Preset != "" {
+		if req.Format != "" && req.Format != "csv" {
+			return response.Error(c, fiber.StatusBadRequest, "options.columns/preset is only supported for csv")
+		}
+		if _, err := parseExportColumns(req.Options.Preset, req.Options.Columns); err != nil {
+			return response.Error(c, fiber.StatusBadRequest, err.Error())
+		}
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.Enqueue(ctx, req)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	return response.Created(c, res)
+}
+
+func (h *ExportController) getByID(c *fiber.Ctx) error {
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.Get(ctx, c.Params("id"))
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	return response.Success(c, res, nil)
+}
+
+// downloadChunk: ukuran potongan file per flush (write deadline diperpanjang
+// setiap flush, lihat streamBody).
+const downloadChunk = 256 * 1024
+
+func (h *ExportController) download(c *fiber.Ctx) error {
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	path, name, err := h.svc.Artifact(ctx, c.Params("id"))
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "export artifact is unavailable")
+	}
+	c.Attachment(name) // Content-Type dari ekstensi
+	c.Set("Cache-Control", "no-store")
+	// file dibaca bertahap di body stream, bukan c.Download (terikat
+	// WriteTimeout server sehingga file besar terpotong)
+	streamBody(c, name, exportTimeout, func(ctx context.Context, w io.Writer, flush func() error) error {
+		defer f.Close()
+		buf := make([]byte, downloadChunk)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n, rerr := f.Read(buf)
+			if n > 0 {
+				if _, err := w.Write(buf[:n]); err != nil {
+					return err
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if errors.Is(rerr, io.EOF) {
+				return nil
+			}
+			if rerr != nil {
+				return rerr
+			}
+		}
+	})
+	return nil
+}
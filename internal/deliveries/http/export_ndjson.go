@@ -0,0 +1,155 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- NDJSON / JSON Lines export
+//
+// Satu transaction.Response per baris; metadata tetap objek JSON bersarang.
+// ?gzip=true => file .ndjson.gz; selain itu body dikompres gzip saat
+// transfer jika client mengirim Accept-Encoding: gzip.
+
+const ndjsonContentType = "application/x-ndjson"
+
+type ndjsonFormat struct {
+	h          *TransactionController
+	extension  string // ndjson | jsonl
+	gzipFile   bool   // ?gzip=true
+	gzipAccept bool   // Accept-Encoding: gzip
+}
+
+func newNDJSONFormat(h *TransactionController, c *fiber.Ctx, extension string) (*ndjsonFormat, error) {
+	if c.Query("bundle", "") != "" {
+		return nil, errors.New("bundle is not supported for " + extension)
+	}
+	if err := rejectColumnParams(c, extension); err != nil {
+		return nil, err
+	}
+	return &ndjsonFormat{
+		h:          h,
+		extension:  extension,
+		gzipFile:   c.Query("gzip") == "true",
+		gzipAccept: strings.Contains(c.Get(fiber.HeaderAcceptEncoding), "gzip"),
+	}, nil
+}
+
+func (f *ndjsonFormat) ext() string {
+	if f.gzipFile {
+		return f.extension + ".gz"
+	}
+	return f.extension
+}
+
+func (f *ndjsonFormat) linkQuery() url.Values {
+	q := url.Values{}
+	if f.gzipFile {
+		q.Set("gzip", "true")
+	}
+	return q
+}
+
+func (f *ndjsonFormat) bundleQuery() url.Values { return f.linkQuery() }
+
+func (f *ndjsonFormat) defaultSplit() exportSplit {
+	return exportSplit{By: splitBytes, Size: defaultSplitBytes}
+}
+
+func (f *ndjsonFormat) bundled() bool { return false }
+
+func (f *ndjsonFormat) bundleSingle() bool { return false }
+
+// estimateSize: NDJSON tidak punya header; ?gzip=true => ukuran terkompresi.
+func (f *ndjsonFormat) estimateSize(ctx context.Context, fetch rowFetcher) (int64, int, error) {
+	var counter countingWriter
+	if !f.gzipFile {
+		_, err := writeNDJSONTo(ctx, &counter, fetch, streamFlushRows, func(int64) error { return nil })
+		return counter.n, 0, err
+	}
+	zw := gzip.NewWriter(&counter)
+	_, err := writeNDJSONTo(ctx, zw, fetch, streamFlushRows, func(int64) error { return nil })
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	return counter.n, 0, err
+}
+
+func (f *ndjsonFormat) writeBundle(c *fiber.Ctx, _ *transaction.Snapshot, _ []transaction.SnapshotPart) error {
+	return response.Error(c, fiber.StatusBadRequest, "bundle is not supported for "+f.extension)
+}
+
+func (f *ndjsonFormat) writeFile(c *fiber.Ctx, fname string, fetch rowFetcher) error {
+	compress := f.gzipFile || f.gzipAccept
+	c.Attachment(fname) // set Content-Type dari ekstensi, ditimpa di bawah
+	switch {
+	case f.gzipFile:
+		c.Set(fiber.HeaderContentType, "application/gzip")
+	case f.gzipAccept:
+		c.Set(fiber.HeaderContentType, ndjsonContentType)
+		c.Set(fiber.HeaderContentEncoding, "gzip")
+		c.Vary(fiber.HeaderAcceptEncoding)
+	default:
+		c.Set(fiber.HeaderContentType, ndjsonContentType)
+	}
+	c.Set("Cache-Control", "no-store")
+
+	f.h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		if !compress {
+			_, err := writeNDJSONTo(ctx, w, fetch, streamFlushRows, func(int64) error { return flush() })
+			return err
+		}
+		zw := gzip.NewWriter(w)
+		_, err := writeNDJSONTo(ctx, zw, fetch, streamFlushRows, func(int64) error {
+			if err := zw.Flush(); err != nil {
+				return err
+			}
+			return flush()
+		})
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+	return nil
+}
+
+// writeNDJSONTo menulis satu objek JSON per baris dan memanggil onFlush
+// setiap `every` baris.
+func writeNDJSONTo(ctx context.Context, out io.Writer, fetch rowFetcher, every int64, onFlush func(rows int64) error) (int64, error) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	var rows int64
+	err := fetch(ctx, func(it transaction.Response) error {
+		if err := enc.Encode(it); err != nil {
+			return err
+		}
+		rows++
+		if rows%every == 0 {
+			return onFlush(rows)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (h *TransactionController) exportNDJSON(c *fiber.Ctx) error {
+	ext := "ndjson"
+	if strings.HasSuffix(c.Path(), ".jsonl") {
+		ext = "jsonl"
+	}
+	format, err := newNDJSONFormat(h, c, ext)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	return h.runExport(c, format)
+}
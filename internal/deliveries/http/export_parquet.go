@@ -0,0 +1,216 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"github.com/parquet-go/parquet-go"
+	"github.com/shopspring/decimal"
+)
+
+// ---- Apache Parquet export
+//
+// File kolumnar bertipe untuk DuckDB/Spark: tanggal sebagai TIMESTAMP,
+// amount sebagai DECIMAL, kolom string dictionary-encoded, metadata kolom
+// JSON. Baris ditulis per row group sambil di-stream dari repository, jadi
+// memori dibatasi ukuran satu row group.
+
+const (
+	parquetContentType  = "application/vnd.apache.parquet"
+	parquetRowGroupRows = 50_000    // baris per row group
+	parquetBatchRows    = 500       // baris per panggilan Write
+	parquetPartRows     = 1_000_000 // default baris per file bila di-split
+)
+
+// parquetRow adalah skema file parquet (urutan kolom = urutan field).
+type parquetRow struct {
+	TransactionID          string    `parquet:"transaction_id"`
+	NoRef                  string    `parquet:"no_ref"`
+	OrderTypeCode          string    `parquet:"order_type_code,dict"`
+	OrderTypeName          string    `parquet:"order_type_name,dict"`
+	TransactionTypeCode    string    `parquet:"transaction_type_code,dict"`
+	TransactionTypeName    string    `parquet:"transaction_type_name,dict"`
+	TransactionDate        time.Time `parquet:"transaction_date,timestamp(microsecond)"`
+	FromAccountNumber      string    `parquet:"from_account_number,dict"`
+	FromAccountName        string    `parquet:"from_account_name,dict"`
+	FromAccountProductName string    `parquet:"from_account_product_name,dict"`
+	ToAccountNumber        string    `parquet:"to_account_number,dict"`
+	ToAccountName          string    `parquet:"to_account_name,dict"`
+	ToAccountProductName   string    `parquet:"to_account_product_name,dict"`
+	Amount                 [16]byte  `parquet:"amount,decimal(4:38)"` // = NUMERIC(20,4)
+	Status                 string    `parquet:"status,dict"`
+	Description            string    `parquet:"description,dict"`
+	Method                 string    `parquet:"method,dict"`
+	Currency               string    `parquet:"currency,dict"`
+	Metadata               string    `parquet:"metadata,optional,json"` // kosong => null
+	CreatedAt              time.Time `parquet:"created_at,timestamp(microsecond)"`
+	UpdatedAt              time.Time `parquet:"updated_at,timestamp(microsecond)"`
+	ParentTransactionID    string    `parquet:"parent_transaction_id,optional"` // kosong => null
+	// timestamp nullable: unix mikrodetik, 0 => null (time.Time zero tidak ditulis null)
+	StatusChangedAt int64 `parquet:"status_changed_at,optional,timestamp(microsecond)"`
+	DeletedAt       int64 `parquet:"deleted_at,optional,timestamp(microsecond)"` // lihat kolom export deleted_at
+}
+
+func toParquetRow(it transaction.Response) parquetRow {
+	r := parquetRow{
+		TransactionID:          it.TransactionID,
+		NoRef:                  it.NoRef,
+		OrderTypeCode:          it.OrderTypeCode,
+		OrderTypeName:          it.OrderTypeName,
+		TransactionTypeCode:    it.TransactionTypeCode,
+		TransactionTypeName:    it.TransactionTypeName,
+		TransactionDate:        it.TransactionDate.UTC(),
+		FromAccountNumber:      it.FromAccountNumber,
+		FromAccountName:        it.FromAccountName,
+		FromAccountProductName: it.FromAccountProductName,
+		ToAccountNumber:        it.ToAccountNumber,
+		ToAccountName:          it.ToAccountName,
+		ToAccountProductName:   it.ToAccountProductName,
+		Amount:                 parquetDecimal(it.Amount),
+		Status:                 it.Status,
+		Description:            it.Description,
+		Method:                 it.Method,
+		Currency:               it.Currency,
+		CreatedAt:              it.CreatedAt.UTC(),
+		UpdatedAt:              it.UpdatedAt.UTC(),
+		ParentTransactionID:    it.ParentTransactionID,
+		StatusChangedAt:        unixMicroOrZero(it.StatusChangedAt),
+		DeletedAt:              unixMicroOrZero(it.DeletedAt),
+	}
+	if len(it.Metadata) > 0 && string(it.Metadata) != "null" {
+		r.Metadata = string(it.Metadata)
+	}
+	return r
+}
+
+func unixMicroOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixMicro()
+}
+
+// parquetDecimal: unscaled value (skala AmountScale) sebagai big-endian
+// two's complement 16 byte, sesuai DECIMAL(38,4) FIXED_LEN_BYTE_ARRAY.
+func parquetDecimal(d decimal.Decimal) [16]byte {
+	var out [16]byte
+	v := d.Shift(transaction.AmountScale).Round(0).BigInt()
+	if v.Sign() < 0 {
+		// two's complement: 2^128 + v
+		v.Add(v, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	v.FillBytes(out[:])
+	return out
+}
+
+// parquetFormat: export.parquet. Tidak mendukung bundle; split menghasilkan
+// manifest dengan satu file .parquet per part.
+type parquetFormat struct{ h *TransactionController }
+
+func newParquetFormat(h *TransactionController, c *fiber.Ctx) (*parquetFormat, error) {
+	if c.Query("bundle", "") != "" {
+		return nil, errors.New("bundle is not supported for parquet")
+	}
+	if err := rejectColumnParams(c, "parquet"); err != nil {
+		return nil, err
+	}
+	return &parquetFormat{h: h}, nil
+}
+
+func (f *parquetFormat) ext() string { return "parquet" }
+
+func (f *parquetFormat) linkQuery() url.Values { return url.Values{} }
+
+func (f *parquetFormat) bundleQuery() url.Values { return url.Values{} }
+
+func (f *parquetFormat) defaultSplit() exportSplit {
+	return exportSplit{By: splitRows, Size: parquetPartRows}
+}
+
+func (f *parquetFormat) bundled() bool { return false }
+
+func (f *parquetFormat) bundleSingle() bool { return false }
+
+func (f *parquetFormat) estimateSize(ctx context.Context, fetch rowFetcher) (int64, int, error) {
+	return estimateEncoded(ctx, fetch, func(ctx context.Context, w io.Writer, fetch rowFetcher) error {
+		_, err := writeParquetTo(ctx, w, fetch, parquetRowGroupRows, func(int64) error { return nil })
+		return err
+	})
+}
+
+func (f *parquetFormat) writeBundle(c *fiber.Ctx, _ *transaction.Snapshot, _ []transaction.SnapshotPart) error {
+	return response.Error(c, fiber.StatusBadRequest, "bundle is not supported for parquet")
+}
+
+func (f *parquetFormat) writeFile(c *fiber.Ctx, fname string, fetch rowFetcher) error {
+	c.Attachment(fname)
+	c.Set(fiber.HeaderContentType, parquetContentType)
+	c.Set("Cache-Control", "no-store")
+
+	f.h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		_, err := writeParquetTo(ctx, w, fetch, parquetRowGroupRows, func(int64) error { return flush() })
+		return err
+	})
+	return nil
+}
+
+// writeParquetTo menulis file parquet; setiap `every` baris row group ditutup
+// (di-flush ke out) lalu onFlush dipanggil.
+func writeParquetTo(ctx context.Context, out io.Writer, fetch rowFetcher, every int64, onFlush func(rows int64) error) (int64, error) {
+	pw := parquet.NewGenericWriter[parquetRow](out,
+		parquet.Compression(&parquet.Snappy),
+		parquet.CreatedBy("go-download-csv", "", ""),
+	)
+	batch := make([]parquetRow, 0, parquetBatchRows)
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := pw.Write(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	var rows int64
+	err := fetch(ctx, func(it transaction.Response) error {
+		batch = append(batch, toParquetRow(it))
+		rows++
+		if len(batch) == cap(batch) {
+			if err := writeBatch(); err != nil {
+				return err
+			}
+		}
+		if rows%every == 0 {
+			if err := writeBatch(); err != nil {
+				return err
+			}
+			if err := pw.Flush(); err != nil {
+				return err
+			}
+			return onFlush(rows)
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeBatch()
+	}
+	if err != nil {
+		return rows, err
+	}
+	return rows, pw.Close()
+}
+
+func (h *TransactionController) exportParquet(c *fiber.Ctx) error {
+	format, err := newParquetFormat(h, c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	return h.runExport(c, format)
+}
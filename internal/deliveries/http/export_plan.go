@@ -0,0 +1,235 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportFormat adalah satu format file export (csv, xlsx, ...). Filter,
+// strategi split, snapshot & manifest sama untuk semua format; format hanya
+// menentukan cara menulis file.
+type exportFormat interface {
+	ext() string
+	// linkQuery: parameter format yang ikut dibawa link part di manifest.
+	linkQuery() url.Values
+	// bundleQuery: parameter untuk mengunduh semua part dalam satu response.
+	bundleQuery() url.Values
+	// defaultSplit dipakai jika ?split tidak diisi.
+	defaultSplit() exportSplit
+	// bundled: true jika request meminta semua part dalam satu response
+	// (ZIP / workbook multi-sheet) alih-alih manifest.
+	bundled() bool
+	// bundleSingle: hasil satu file pun tetap dikirim sebagai bundle (ZIP).
+	bundleSingle() bool
+	// estimateSize untuk split=bytes: body = total byte semua baris dalam
+	// format ini, header = byte yang diulang di awal setiap part.
+	estimateSize(ctx context.Context, fetch rowFetcher) (body int64, header int, err error)
+
+	writeFile(c *fiber.Ctx, fname string, fetch rowFetcher) error
+	writeBundle(c *fiber.Ctx, snap *transaction.Snapshot, parts []transaction.SnapshotPart) error
+}
+
+// runExport: ?snapshot=...(&part=N) => baca dari snapshot; selain itu hitung
+// rencana split lalu kirim satu file, bundle, atau manifest JSON.
+func (h *TransactionController) runExport(c *fiber.Ctx, format exportFormat) error {
+	// --- link part dari manifest (?snapshot=...&part=N) => baca dari snapshot
+	if token := c.Query("snapshot", ""); token != "" {
+		return h.exportSnapshotPart(c, format, token)
+	}
+	if c.Query("part", "") != "" {
+		return response.Error(c, fiber.StatusBadRequest, "part requires snapshot token")
+	}
+
+	f, err := parseFilter(c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	split, err := parseExportSplit(c, format.defaultSplit())
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), h.exportTimeout)
+	defer cancel()
+
+	single := func() error {
+		return format.writeFile(c, exportFileName(f.From, f.To, "", format.ext()), func(ctx context.Context, fn func(transaction.Response) error) error {
+			return h.svc.Export(ctx, f, 0, 0, fn)
+		})
+	}
+
+	meta := fiber.Map{"split": split.By}
+	numParts := 0
+	switch split.By {
+	case splitBytes:
+		// --- hitung ukuran (sesuai format) dengan sekali stream (tanpa buffer)
+		body, headerBytes, err := format.estimateSize(ctx, func(ctx context.Context, fn func(transaction.Response) error) error {
+			return h.svc.Export(ctx, f, 0, 0, fn)
+		})
+		if err != nil {
+			return response.Error(c, fiber.StatusInternalServerError, err.Error())
+		}
+
+		// tiap part akan memiliki header sendiri, jadi kira numParts dengan overhead header
+		totalBytes := int(body) + headerBytes
+		chunkLimit := split.chunkBytes(totalBytes, headerBytes)
+		numParts = int(math.Ceil((float64(totalBytes) + float64(headerBytes)) / (float64(chunkLimit) + float64(headerBytes))))
+		if numParts < 1 {
+			numParts = 1
+		}
+		if numParts == 1 && totalBytes <= chunkLimit && !format.bundleSingle() {
+			return single()
+		}
+		meta["total_bytes_estimate"] = totalBytes
+		meta["chunk_limit_bytes"] = chunkLimit
+	case splitRows:
+		total, err := h.svc.Count(ctx, f)
+		if err != nil {
+			return response.Error(c, fiber.StatusInternalServerError, err.Error())
+		}
+		if total <= int64(split.Size) && !format.bundleSingle() {
+			return single()
+		}
+		numParts = int((total + int64(split.Size) - 1) / int64(split.Size))
+		meta["rows_per_part"] = split.Size
+	default:
+		n, err := h.svc.CountGroups(ctx, f, split.groupBy())
+		if err != nil {
+			return response.Error(c, fiber.StatusInternalServerError, err.Error())
+		}
+		numParts = int(n)
+	}
+	// tolak sebelum snapshot: snapshot menyalin seluruh baris yang cocok
+	if err := checkPartCount(numParts); err != nil {
+		return response.Error(c, fiber.StatusUnprocessableEntity, err.Error())
+	}
+
+	// --- mode MANIFEST: bekukan himpunan baris dulu supaya semua part
+	// menjumlah ke baris yang sama walau data berubah di antara download
+	snap, err := h.svc.CreateSnapshot(ctx, f, split.groupBy(), snapshotTTL)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+	var parts []transaction.SnapshotPart
+	switch split.By {
+	case splitBytes:
+		parts = evenParts(snap.RowCount, numParts)
+	case splitRows:
+		parts = rowParts(snap.RowCount, split.Size)
+	default:
+		if parts, err = h.svc.SnapshotGroups(ctx, snap); err != nil {
+			return response.Error(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+	// data bisa bertambah di antara hitungan di atas dan snapshot
+	if err := checkPartCount(len(parts)); err != nil {
+		return response.Error(c, fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if err := h.svc.SetSnapshotParts(ctx, snap.ID, parts); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	// --- mode BUNDLE: semua part dalam satu response
+	if format.bundled() {
+		return format.writeBundle(c, snap, parts)
+	}
+
+	base := c.BaseURL() + c.Path()
+	links := make([]string, 0, len(parts))
+	items := make([]fiber.Map, 0, len(parts))
+	names := partFileNames(f, parts, format.ext())
+	for i, p := range parts {
+		q := format.linkQuery()
+		q.Set("snapshot", snap.ID)
+		q.Set("part", strconv.Itoa(i+1))
+		link := base + "?" + q.Encode()
+		links = append(links, link)
+		items = append(items, fiber.Map{
+			"part":      i + 1,
+			"key":       p.Key,
+			"file_name": names[i],
+			"rows":      p.Rows(),
+			"url":       link,
+		})
+	}
+
+	meta["total_rows"] = snap.RowCount
+	meta["num_parts"] = len(parts)
+	meta["snapshot"] = snap.ID
+	meta["snapshot_expires_at"] = snap.ExpiresAt
+
+	bq := format.bundleQuery()
+	bq.Set("snapshot", snap.ID)
+	data := fiber.Map{"links": links, "parts": items, "bundle_url": base + "?" + bq.Encode()}
+	return response.Success(c, data, meta)
+}
+
+// exportSnapshotPart men-stream satu part manifest (atau seluruh part jika
+// bundle) dari snapshot yang dipin.
+func (h *TransactionController) exportSnapshotPart(c *fiber.Ctx, format exportFormat, token string) error {
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	snap, err := h.svc.GetSnapshot(ctx, token)
+	if err != nil {
+		return response.Error(c, fiber.StatusNotFound, err.Error())
+	}
+	parts, err := snap.DecodeParts()
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+	if format.bundled() {
+		return format.writeBundle(c, snap, parts)
+	}
+	part, err := strconv.Atoi(c.Query("part", ""))
+	if err != nil || part < 1 || part > len(parts) {
+		return response.Error(c, fiber.StatusBadRequest, "invalid part")
+	}
+	f, _ := snap.DecodeFilter()
+
+	p := parts[part-1]
+	return format.writeFile(c, partFileNames(f, parts, format.ext())[part-1], h.snapshotFetcher(snap.ID, p))
+}
+
+func (h *TransactionController) snapshotFetcher(id string, p transaction.SnapshotPart) rowFetcher {
+	return func(ctx context.Context, fn func(transaction.Response) error) error {
+		return h.svc.ExportSnapshot(ctx, id, p, fn)
+	}
+}
+
+// estimateCSVSize: ukuran file CSV dengan kolom cols.
+func estimateCSVSize(ctx context.Context, cols exportColumns, fetch rowFetcher) (int64, int, error) {
+	var counter countingWriter
+	cw := csv.NewWriter(&counter)
+	err := fetch(ctx, func(it transaction.Response) error {
+		return cols.writeCSVRow(cw, it)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	cw.Flush()
+	return counter.n, cols.csvHeaderBytes(), cw.Error()
+}
+
+// estimateEncoded: ukuran file biner (XLSX, Parquet) dengan menjalankan
+// encoder-nya ke countingWriter. header = ukuran file tanpa baris (zip
+// entries / footer) yang ikut ada di setiap part.
+func estimateEncoded(ctx context.Context, fetch rowFetcher, write func(ctx context.Context, w io.Writer, fetch rowFetcher) error) (int64, int, error) {
+	var empty, full countingWriter
+	none := func(context.Context, func(transaction.Response) error) error { return nil }
+	if err := write(ctx, &empty, none); err != nil {
+		return 0, 0, err
+	}
+	if err := write(ctx, &full, fetch); err != nil {
+		return 0, 0, err
+	}
+	return max(full.n-empty.n, 0), int(empty.n), nil
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"io"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/export"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+)
+
+// jumlah baris antar laporan progres job
+const jobProgressRows = 1000
+
+// exportRenderer menulis file job export memakai writer yang sama dengan
+// GET /v1/transactions/export.csv, export.ndjson & export.parquet.
+type exportRenderer struct{ svc transaction.Service }
+
+func NewExportRenderer(svc transaction.Service) export.Renderer {
+	return &exportRenderer{svc: svc}
+}
+
+func (r *exportRenderer) FileName(job *export.Job) string {
+	f, _ := job.DecodeFilter()
+	return exportFileName(f.From, f.To, "", job.Format)
+}
+
+func (r *exportRenderer) Render(ctx context.Context, job *export.Job, out io.Writer, progress func(int64)) (int64, error) {
+	f, err := job.DecodeFilter()
+	if err != nil {
+		return 0, err
+	}
+	opts, err := job.DecodeOptions()
+	if err != nil {
+		return 0, err
+	}
+
+	fetch := func(ctx context.Context, fn func(transaction.Response) error) error {
+		return r.svc.Export(ctx, f, 0, 0, fn)
+	}
+	onFlush := func(rows int64) error {
+		progress(rows)
+		return nil
+	}
+	cols, err := parseExportColumns(opts.Preset, opts.Columns)
+	if err != nil {
+		return 0, err
+	}
+	switch job.Format {
+	case "ndjson":
+		return writeNDJSONTo(ctx, out, fetch, jobProgressRows, onFlush)
+	case "parquet":
+		return writeParquetTo(ctx, out, fetch, parquetRowGroupRows, onFlush)
+	default:
+		return writeCSVTo(ctx, out, opts.Excel, cols, fetch, jobProgressRows, onFlush)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- strategi split export (?split=...&split_size=N)
+
+const (
+	splitBytes   = "bytes"   // per ukuran file (split_size = byte per part)
+	splitRows    = "rows"    // per jumlah baris (split_size = baris per part)
+	splitDay     = "day"     // satu file per hari transaction_date
+	splitMonth   = "month"   // satu file per bulan transaction_date
+	splitAccount = "account" // satu file per from_account_number
+
+	defaultSplitBytes = 10 * 1024 // 10KB
+	minSplitBytes     = 1024
+	defaultSplitRows  = 100_000
+	maxExportParts    = 10_000
+)
+
+type exportSplit struct {
+	By   string
+	Size int
+	// Auto: split=bytes tanpa split_size => Size dinaikkan untuk tabel besar
+	// supaya jumlah part tidak melewati maxExportParts.
+	Auto bool
+}
+
+// parseExportSplit membaca ?split & ?split_size; def dipakai jika split tidak
+// diisi (tiap format punya default sendiri).
+func parseExportSplit(c *fiber.Ctx, def exportSplit) (exportSplit, error) {
+	s := exportSplit{By: strings.ToLower(c.Query("split", def.By))}
+	switch s.By {
+	case splitBytes:
+		s.Size = defaultSplitBytes
+	case splitRows:
+		s.Size = defaultSplitRows
+		if def.By == splitRows {
+			s.Size = def.Size
+		}
+	case splitDay, splitMonth, splitAccount:
+		return s, nil
+	default:
+		return s, fmt.Errorf("invalid split %q (bytes|rows|day|month|account)", s.By)
+	}
+	v := c.Query("split_size", "")
+	s.Auto = v == "" && s.By == splitBytes
+	if v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return s, fmt.Errorf("invalid split_size %q", v)
+		}
+		s.Size = n
+	}
+	if s.By == splitBytes && s.Size < minSplitBytes {
+		return s, fmt.Errorf("split_size must be at least %d bytes", minSplitBytes)
+	}
+	return s, nil
+}
+
+// chunkBytes: byte per part untuk totalBytes; default yang tidak diisi
+// diperbesar seperlunya supaya paling banyak maxExportParts part.
+func (s exportSplit) chunkBytes(totalBytes, headerBytes int) int {
+	if !s.Auto {
+		return s.Size
+	}
+	return max(s.Size, int(math.Ceil(float64(totalBytes+headerBytes)/maxExportParts)))
+}
+
+// checkPartCount menolak export dengan part lebih dari maxExportParts.
+func checkPartCount(n int) error {
+	if n > maxExportParts {
+		return fmt.Errorf("too many parts (%d > %d), use a larger split_size or a narrower filter", n, maxExportParts)
+	}
+	return nil
+}
+
+// groupBy memetakan split berbasis key ke pengelompokan snapshot.
+func (s exportSplit) groupBy() string {
+	switch s.By {
+	case splitDay:
+		return transaction.GroupByDay
+	case splitMonth:
+		return transaction.GroupByMonth
+	case splitAccount:
+		return transaction.GroupByAccount
+	}
+	return ""
+}
+
+// rowParts memotong rowCount baris menjadi part berisi maksimal size baris.
+func rowParts(rowCount int64, size int) []transaction.SnapshotPart {
+	var parts []transaction.SnapshotPart
+	for start := int64(0); start < rowCount; start += int64(size) {
+		end := start + int64(size)
+		if end > rowCount {
+			end = rowCount
+		}
+		parts = append(parts, transaction.SnapshotPart{Start: start, End: end})
+	}
+	return parts
+}
+
+// evenParts membagi rowCount baris menjadi numParts part yang kira-kira sama rata.
+func evenParts(rowCount int64, numParts int) []transaction.SnapshotPart {
+	parts := make([]transaction.SnapshotPart, numParts)
+	for i := range parts {
+		start, end := splitRange(int(rowCount), numParts, i+1)
+		parts[i] = transaction.SnapshotPart{Start: int64(start), End: int64(end)}
+	}
+	return parts
+}
+
+// partFileNames: part berlabel key => transactions_<key>.<ext>, selain itu
+// transactions[_from_to_]_part_N_of_M.<ext>. Key berbeda yang menjadi nama
+// sama setelah safeFileKey (ACC/1 & ACC_1) diberi akhiran _2, _3, ... sesuai
+// urutan part, jadi nama part N selalu sama di manifest, bundle dan download.
+func partFileNames(f transaction.Filter, parts []transaction.SnapshotPart, ext string) []string {
+	names := make([]string, len(parts))
+	used := make(map[string]bool, len(parts))
+	for i, p := range parts {
+		if p.Key == "" {
+			names[i] = exportFileName(f.From, f.To, fmt.Sprintf("_part_%d_of_%d", i+1, len(parts)), ext)
+			continue
+		}
+		base := "transactions_" + safeFileKey(p.Key)
+		name := base
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name + "." + ext
+	}
+	return names
+}
+
+// safeFileKey membuang karakter yang tidak aman untuk nama file.
+func safeFileKey(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		ch := key[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '-', ch == '_', ch == '.':
+			b = append(b, ch)
+		default:
+			b = append(b, '_')
+		}
+	}
+	if len(b) == 0 {
+		return "empty"
+	}
+	return string(b)
+}
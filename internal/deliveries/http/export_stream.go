@@ -0,0 +1,232 @@
+package http
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/gofiber/fiber/v2"
+)
+
+// rowFetcher men-stream baris export ke fn.
+type rowFetcher func(ctx context.Context, fn func(transaction.Response) error) error
+
+// streamBody menjalankan write setelah handler selesai (body stream fasthttp).
+// Query memakai context sendiri (exportTimeout) dan write deadline koneksi
+// diperpanjang setiap flush agar WriteTimeout server tidak memotong download
+// yang masih berjalan.
+func (h *TransactionController) streamBody(c *fiber.Ctx, name string, write func(ctx context.Context, w io.Writer, flush func() error) error) {
+	streamBody(c, name, h.exportTimeout, write)
+}
+
+func streamBody(c *fiber.Ctx, name string, timeout time.Duration, write func(ctx context.Context, w io.Writer, flush func() error) error) {
+	conn := c.Context().Conn()
+	c.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		flush := func() error {
+			if conn != nil {
+				_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			}
+			return bw.Flush() // gagal jika client sudah putus => hentikan query
+		}
+		err := write(ctx, bw, flush)
+		if ferr := flush(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			log.Printf("export %s: stream aborted: %v", name, err)
+		}
+	})
+}
+
+// csvFormat: export.csv, ?excel=true menambah BOM, ?bundle=zip => ZIP.
+type csvFormat struct {
+	h      *TransactionController
+	excel  bool
+	bundle bool
+	cols   exportColumns
+}
+
+func newCSVFormat(h *TransactionController, c *fiber.Ctx) (*csvFormat, error) {
+	bundle := c.Query("bundle", "")
+	if bundle != "" && bundle != "zip" {
+		return nil, errors.New("invalid bundle (zip)")
+	}
+	cols, err := parseExportColumns(c.Query("preset"), c.Query("columns"))
+	if err != nil {
+		return nil, err
+	}
+	return &csvFormat{h: h, excel: c.Query("excel") == "true", bundle: bundle == "zip", cols: cols}, nil
+}
+
+func (f *csvFormat) ext() string { return "csv" }
+
+func (f *csvFormat) linkQuery() url.Values {
+	q := url.Values{}
+	// bawa flag excel jika ada
+	if f.excel {
+		q.Set("excel", "true")
+	}
+	if spec := f.cols.spec(); spec != "" {
+		q.Set("columns", spec)
+	}
+	return q
+}
+
+func (f *csvFormat) bundleQuery() url.Values {
+	q := f.linkQuery()
+	q.Set("bundle", "zip")
+	return q
+}
+
+func (f *csvFormat) defaultSplit() exportSplit {
+	return exportSplit{By: splitBytes, Size: defaultSplitBytes}
+}
+
+func (f *csvFormat) bundled() bool { return f.bundle }
+
+func (f *csvFormat) bundleSingle() bool { return f.bundle }
+
+func (f *csvFormat) estimateSize(ctx context.Context, fetch rowFetcher) (int64, int, error) {
+	return estimateCSVSize(ctx, f.cols, fetch)
+}
+
+func (f *csvFormat) writeFile(c *fiber.Ctx, fname string, fetch rowFetcher) error {
+	return f.h.streamCSV(c, fname, f.excel, f.cols, fetch)
+}
+
+func (f *csvFormat) writeBundle(c *fiber.Ctx, snap *transaction.Snapshot, parts []transaction.SnapshotPart) error {
+	return f.h.streamZip(c, snap, parts, f.excel, f.cols)
+}
+
+// streamCSV menulis CSV langsung ke koneksi saat baris dibaca dari database.
+func (h *TransactionController) streamCSV(c *fiber.Ctx, fname string, excel bool, cols exportColumns, fetch rowFetcher) error {
+	c.Type("csv")                      // Content-Type: text/csv
+	c.Set("Cache-Control", "no-store") // jangan cache
+	c.Attachment(fname)
+
+	h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		_, err := writeCSVTo(ctx, w, excel, cols, fetch, streamFlushRows, func(int64) error { return flush() })
+		return err
+	})
+	return nil
+}
+
+// writeCSVTo menulis header + seluruh baris (kolom cols) dari fetch ke out dan
+// memanggil onFlush setiap `every` baris (setelah buffer CSV di-flush).
+func writeCSVTo(ctx context.Context, out io.Writer, excel bool, cols exportColumns, fetch rowFetcher, every int64, onFlush func(rows int64) error) (int64, error) {
+	// optional: BOM untuk Excel Windows
+	if excel {
+		if _, err := out.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+			return 0, err
+		}
+	}
+	w := csv.NewWriter(out)
+	if err := cols.writeCSVHeader(w); err != nil {
+		return 0, err
+	}
+	var rows int64
+	err := fetch(ctx, func(it transaction.Response) error {
+		if err := cols.writeCSVRow(w, it); err != nil {
+			return err
+		}
+		rows++
+		if rows%every == 0 {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+			return onFlush(rows)
+		}
+		return nil
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	return rows, err
+}
+
+// ---- ZIP bundle (?bundle=zip)
+
+type bundleEntry struct {
+	FileName string `json:"file_name"`
+	Key      string `json:"key,omitempty"`
+	Rows     int64  `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+	SHA256   string `json:"sha256"`
+}
+
+type bundleManifest struct {
+	Snapshot    string             `json:"snapshot"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Filter      transaction.Filter `json:"filter"`
+	GroupBy     string             `json:"group_by,omitempty"`
+	TotalRows   int64              `json:"total_rows"`
+	Parts       []bundleEntry      `json:"parts"`
+}
+
+// streamZip menulis semua part snapshot sebagai CSV di dalam satu ZIP plus
+// manifest.json. Archive ditulis bertahap (tidak pernah dirakit di memori);
+// ukuran & SHA-256 tiap part dihitung sambil menulis.
+func (h *TransactionController) streamZip(c *fiber.Ctx, snap *transaction.Snapshot, parts []transaction.SnapshotPart, excel bool, cols exportColumns) error {
+	f, _ := snap.DecodeFilter()
+	fname := exportFileName(f.From, f.To, "", "zip")
+
+	c.Type("zip")
+	c.Set("Cache-Control", "no-store")
+	c.Attachment(fname)
+
+	h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		zw := zip.NewWriter(w)
+		manifest := bundleManifest{
+			Snapshot:    snap.ID,
+			GeneratedAt: time.Now(),
+			Filter:      f,
+			GroupBy:     snap.GroupBy,
+			TotalRows:   snap.RowCount,
+			Parts:       make([]bundleEntry, 0, len(parts)),
+		}
+		names := partFileNames(f, parts, "csv")
+		for i, p := range parts {
+			entry := bundleEntry{FileName: names[i], Key: p.Key}
+			zf, err := zw.CreateHeader(&zip.FileHeader{Name: entry.FileName, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+			if err != nil {
+				return err
+			}
+			sum := sha256.New()
+			var size countingWriter
+			entry.Rows, err = writeCSVTo(ctx, io.MultiWriter(zf, sum, &size), excel, cols, h.snapshotFetcher(snap.ID, p), streamFlushRows, func(int64) error { return flush() })
+			if err != nil {
+				return err
+			}
+			entry.Bytes = size.n
+			entry.SHA256 = hex.EncodeToString(sum.Sum(nil))
+			manifest.Parts = append(manifest.Parts, entry)
+		}
+
+		mf, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(mf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+	return nil
+}
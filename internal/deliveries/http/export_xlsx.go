@@ -0,0 +1,460 @@
+package http
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+// ---- XLSX (OOXML) export
+//
+// Workbook ditulis manual (zip + XML) secara bertahap, jadi memori tetap
+// konstan seperti CSV. Nomor rekening & ID selalu sel teks, Amount sel angka
+// dengan format mata uang, Transaction Date sel tanggal asli, header dibekukan.
+
+const (
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	xlsxMaxRows     = 1_048_576 // batas baris per sheet Excel (termasuk header)
+	xlsxMaxSheet    = 31        // batas panjang nama sheet
+
+	// index cellXfs di styles.xml
+	xlsxStyleHeader   = 1
+	xlsxStyleDate     = 2
+	xlsxStyleCurrency = 3 // format mata uang mulai dari sini
+)
+
+// xlsxFormat: export.xlsx. layout=sheets (default) => semua part menjadi
+// sheet dalam satu workbook; layout=files => manifest, satu .xlsx per part.
+type xlsxFormat struct {
+	h     *TransactionController
+	files bool
+	cols  exportColumns
+}
+
+func newXLSXFormat(h *TransactionController, c *fiber.Ctx) (*xlsxFormat, error) {
+	if c.Query("bundle", "") != "" {
+		return nil, errors.New("bundle is not supported for xlsx (use layout=sheets)")
+	}
+	cols, err := parseExportColumns(c.Query("preset"), c.Query("columns"))
+	if err != nil {
+		return nil, err
+	}
+	switch layout := c.Query("layout", "sheets"); layout {
+	case "sheets":
+		return &xlsxFormat{h: h, cols: cols}, nil
+	case "files":
+		return &xlsxFormat{h: h, files: true, cols: cols}, nil
+	default:
+		return nil, fmt.Errorf("invalid layout %q (sheets|files)", layout)
+	}
+}
+
+func (f *xlsxFormat) ext() string { return "xlsx" }
+
+func (f *xlsxFormat) linkQuery() url.Values {
+	q := url.Values{}
+	q.Set("layout", "files")
+	if spec := f.cols.spec(); spec != "" {
+		q.Set("columns", spec)
+	}
+	return q
+}
+
+func (f *xlsxFormat) bundleQuery() url.Values {
+	q := url.Values{}
+	if spec := f.cols.spec(); spec != "" {
+		q.Set("columns", spec)
+	}
+	return q
+}
+
+// default: satu sheet/file per batas baris Excel
+func (f *xlsxFormat) defaultSplit() exportSplit {
+	return exportSplit{By: splitRows, Size: xlsxMaxRows - 1}
+}
+
+func (f *xlsxFormat) bundled() bool { return !f.files }
+
+// satu file = workbook satu sheet, tidak perlu snapshot
+func (f *xlsxFormat) bundleSingle() bool { return false }
+
+func (f *xlsxFormat) estimateSize(ctx context.Context, fetch rowFetcher) (int64, int, error) {
+	return estimateEncoded(ctx, fetch, func(ctx context.Context, w io.Writer, fetch rowFetcher) error {
+		x := newXLSXWriter(w, f.cols)
+		if err := x.writeSheet(ctx, "Transactions", fetch, func() error { return nil }); err != nil {
+			return err
+		}
+		return x.Close()
+	})
+}
+
+func (f *xlsxFormat) writeFile(c *fiber.Ctx, fname string, fetch rowFetcher) error {
+	return f.h.streamXLSX(c, fname, f.cols, []xlsxSheet{{name: "Transactions", fetch: fetch}})
+}
+
+func (f *xlsxFormat) writeBundle(c *fiber.Ctx, snap *transaction.Snapshot, parts []transaction.SnapshotPart) error {
+	filter, _ := snap.DecodeFilter()
+	sheets := make([]xlsxSheet, 0, len(parts))
+	for i, p := range parts {
+		name := p.Key
+		switch {
+		case len(parts) == 1 && name == "":
+			name = "Transactions"
+		case name == "":
+			name = fmt.Sprintf("Part %d", i+1)
+		}
+		sheets = append(sheets, xlsxSheet{name: name, fetch: f.h.snapshotFetcher(snap.ID, p)})
+	}
+	return f.h.streamXLSX(c, exportFileName(filter.From, filter.To, "", "xlsx"), f.cols, sheets)
+}
+
+func (h *TransactionController) exportXLSX(c *fiber.Ctx) error {
+	format, err := newXLSXFormat(h, c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	return h.runExport(c, format)
+}
+
+type xlsxSheet struct {
+	name  string
+	fetch rowFetcher
+}
+
+func (h *TransactionController) streamXLSX(c *fiber.Ctx, fname string, cols exportColumns, sheets []xlsxSheet) error {
+	c.Attachment(fname)
+	c.Set(fiber.HeaderContentType, xlsxContentType)
+	c.Set("Cache-Control", "no-store")
+
+	h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		x := newXLSXWriter(w, cols)
+		for _, s := range sheets {
+			if err := x.writeSheet(ctx, s.name, s.fetch, flush); err != nil {
+				return err
+			}
+		}
+		return x.Close()
+	})
+	return nil
+}
+
+// ---- writer
+
+type xlsxWriter struct {
+	zw       *zip.Writer
+	cols     exportColumns
+	sheets   []string       // nama sheet sesuai urutan file sheetN.xml
+	used     map[string]int // nama sheet (lowercase) yang sudah dipakai
+	currency map[string]int // currency => index style
+	numFmts  []string       // format code untuk style currency
+}
+
+func newXLSXWriter(w io.Writer, cols exportColumns) *xlsxWriter {
+	return &xlsxWriter{
+		zw:       zip.NewWriter(w),
+		cols:     cols,
+		used:     map[string]int{},
+		currency: map[string]int{},
+	}
+}
+
+// writeSheet menulis satu sheet (lanjut ke sheet "nama (2)" dst. bila batas
+// baris Excel terlampaui).
+func (x *xlsxWriter) writeSheet(ctx context.Context, name string, fetch rowFetcher, flush func() error) error {
+	sw, err := x.openSheet(name)
+	if err != nil {
+		return err
+	}
+	cont := 1
+	err = fetch(ctx, func(it transaction.Response) error {
+		if sw.rows == xlsxMaxRows {
+			if err := sw.close(); err != nil {
+				return err
+			}
+			cont++
+			if sw, err = x.openSheet(fmt.Sprintf("%s (%d)", name, cont)); err != nil {
+				return err
+			}
+		}
+		if err := x.writeRow(sw, it); err != nil {
+			return err
+		}
+		if sw.rows%streamFlushRows == 0 {
+			if err := sw.bw.Flush(); err != nil {
+				return err
+			}
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sw.close()
+}
+
+type xlsxSheetWriter struct {
+	bw   *bufio.Writer
+	rows int // jumlah baris yang sudah ditulis (termasuk header)
+}
+
+func (x *xlsxWriter) openSheet(name string) (*xlsxSheetWriter, error) {
+	x.sheets = append(x.sheets, x.uniqueSheetName(name))
+	zf, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if err != nil {
+		return nil, err
+	}
+	sw := &xlsxSheetWriter{bw: bufio.NewWriter(zf)}
+	sw.bw.WriteString(xml.Header)
+	sw.bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	// header dibekukan (freeze pane di bawah baris 1)
+	sw.bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft"/></sheetView></sheetViews>`)
+	fmt.Fprintf(sw.bw, `<cols><col min="1" max="%d" width="22" customWidth="1"/></cols>`, len(x.cols))
+	sw.bw.WriteString(`<sheetData>`)
+
+	sw.startRow()
+	for i, col := range x.cols {
+		sw.text(i, col.Label, xlsxStyleHeader)
+	}
+	sw.bw.WriteString(`</row>`)
+	return sw, nil
+}
+
+func (sw *xlsxSheetWriter) close() error {
+	sw.bw.WriteString(`</sheetData></worksheet>`)
+	return sw.bw.Flush()
+}
+
+func (sw *xlsxSheetWriter) startRow() {
+	sw.rows++
+	sw.bw.WriteString(`<row r="`)
+	sw.bw.WriteString(strconv.Itoa(sw.rows))
+	sw.bw.WriteString(`">`)
+}
+
+func (sw *xlsxSheetWriter) ref(col int) {
+	sw.bw.WriteString(` r="`)
+	sw.bw.WriteString(xlsxColumn(col))
+	sw.bw.WriteString(strconv.Itoa(sw.rows))
+	sw.bw.WriteString(`"`)
+}
+
+func (sw *xlsxSheetWriter) text(col int, v string, style int) {
+	if v == "" && style == 0 {
+		return
+	}
+	sw.bw.WriteString(`<c`)
+	sw.ref(col)
+	if style > 0 {
+		sw.bw.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	sw.bw.WriteString(` t="inlineStr"><is><t xml:space="preserve">`)
+	_ = xml.EscapeText(sw.bw, []byte(v))
+	sw.bw.WriteString(`</t></is></c>`)
+}
+
+func (sw *xlsxSheetWriter) number(col int, v float64, style int) {
+	sw.bw.WriteString(`<c`)
+	sw.ref(col)
+	sw.bw.WriteString(` s="` + strconv.Itoa(style) + `"><v>`)
+	sw.bw.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	sw.bw.WriteString(`</v></c>`)
+}
+
+// decimal menulis nilai persis (tanpa konversi float di sisi server).
+func (sw *xlsxSheetWriter) decimal(col int, v decimal.Decimal, style int) {
+	sw.bw.WriteString(`<c`)
+	sw.ref(col)
+	sw.bw.WriteString(` s="` + strconv.Itoa(style) + `"><v>`)
+	sw.bw.WriteString(v.String())
+	sw.bw.WriteString(`</v></c>`)
+}
+
+// writeRow: kolom sesuai x.cols; tanggal & amount sebagai sel bertipe.
+func (x *xlsxWriter) writeRow(sw *xlsxSheetWriter, it transaction.Response) error {
+	sw.startRow()
+	for i, col := range x.cols {
+		switch col.Kind {
+		case colTime:
+			if t := col.time(it); !t.IsZero() {
+				sw.number(i, excelSerial(t), xlsxStyleDate)
+			}
+		case colAmount:
+			sw.decimal(i, it.Amount, x.currencyStyle(it.Currency))
+		default:
+			sw.text(i, col.text(it), 0)
+		}
+	}
+	_, err := sw.bw.WriteString(`</row>`)
+	return err
+}
+
+// currencyStyle mengembalikan style angka dengan kode mata uang, dibuat saat
+// mata uang tersebut pertama kali muncul (styles.xml ditulis paling akhir).
+func (x *xlsxWriter) currencyStyle(cur string) int {
+	cur = strings.ToUpper(cur)
+	if idx, ok := x.currency[cur]; ok {
+		return idx
+	}
+	code := "#,##0"
+	if scale := transaction.CurrencyScale(cur); scale > 0 {
+		code += "." + strings.Repeat("0", int(scale))
+	}
+	if cur != "" {
+		code += ` "` + cur + `"`
+	}
+	idx := xlsxStyleCurrency + len(x.numFmts)
+	x.numFmts = append(x.numFmts, code)
+	x.currency[cur] = idx
+	return idx
+}
+
+func (x *xlsxWriter) uniqueSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '[', ']', ':', '*', '?', '/', '\\':
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Sheet"
+	}
+	if r := []rune(name); len(r) > xlsxMaxSheet {
+		name = string(r[:xlsxMaxSheet])
+	}
+	base, n := name, 1
+	for x.used[strings.ToLower(name)] > 0 {
+		n++
+		suffix := fmt.Sprintf(" (%d)", n)
+		r := []rune(base)
+		if len(r)+len(suffix) > xlsxMaxSheet {
+			r = r[:xlsxMaxSheet-len(suffix)]
+		}
+		name = string(r) + suffix
+	}
+	x.used[strings.ToLower(name)]++
+	return name
+}
+
+// Close menulis bagian workbook yang bergantung pada daftar sheet & style.
+func (x *xlsxWriter) Close() error {
+	if len(x.sheets) == 0 {
+		// workbook wajib punya minimal satu sheet
+		sw, err := x.openSheet("Transactions")
+		if err != nil {
+			return err
+		}
+		if err := sw.close(); err != nil {
+			return err
+		}
+	}
+
+	var b strings.Builder
+	files := map[string]func(){}
+	order := []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"}
+
+	files["[Content_Types].xml"] = func() {
+		b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+		b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+		b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+		b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+		b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+		for i := range x.sheets {
+			fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		}
+		b.WriteString(`</Types>`)
+	}
+	files["_rels/.rels"] = func() {
+		b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+		b.WriteString(`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>`)
+		b.WriteString(`</Relationships>`)
+	}
+	files["xl/workbook.xml"] = func() {
+		b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+		for i, name := range x.sheets {
+			b.WriteString(`<sheet name="`)
+			_ = xml.EscapeText(&b, []byte(name))
+			fmt.Fprintf(&b, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+		}
+		b.WriteString(`</sheets></workbook>`)
+	}
+	files["xl/_rels/workbook.xml.rels"] = func() {
+		b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+		for i := range x.sheets {
+			fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		}
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(x.sheets)+1)
+		b.WriteString(`</Relationships>`)
+	}
+	files["xl/styles.xml"] = func() {
+		b.WriteString(`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+		fmt.Fprintf(&b, `<numFmts count="%d"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/>`, len(x.numFmts)+1)
+		for i, code := range x.numFmts {
+			fmt.Fprintf(&b, `<numFmt numFmtId="%d" formatCode="`, 165+i)
+			_ = xml.EscapeText(&b, []byte(code))
+			b.WriteString(`"/>`)
+		}
+		b.WriteString(`</numFmts>`)
+		b.WriteString(`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`)
+		b.WriteString(`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>`)
+		b.WriteString(`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`)
+		b.WriteString(`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+		fmt.Fprintf(&b, `<cellXfs count="%d">`, xlsxStyleCurrency+len(x.numFmts))
+		b.WriteString(`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`)
+		b.WriteString(`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`)
+		b.WriteString(`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`)
+		for i := range x.numFmts {
+			fmt.Fprintf(&b, `<xf numFmtId="%d" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`, 165+i)
+		}
+		b.WriteString(`</cellXfs>`)
+		b.WriteString(`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>`)
+		b.WriteString(`</styleSheet>`)
+	}
+
+	for _, name := range order {
+		b.Reset()
+		b.WriteString(xml.Header)
+		files[name]()
+		zf, err := x.zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(zf, b.String()); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// xlsxColumn: 0 => A, 25 => Z, 26 => AA, ...
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// excelSerial mengubah waktu menjadi serial date Excel (hari sejak
+// 1899-12-30). Excel tidak mengenal zona waktu, jadi dipakai jam dinding
+// dari zona waktu nilai tersebut.
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
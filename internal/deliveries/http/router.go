@@ -1,11 +1,35 @@
 package http
 
 import (
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/export"
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
 	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
 )
 
-func RegisterRoutes(app *fiber.App, svc transaction.Service) {
+func RegisterRoutes(app *fiber.App, svc transaction.Service, jobs export.Service, idem idempotency.Service, adminToken string) {
 	r := app.Group("/v1")
-	RegisterTransactionRoutes(r, svc)
+	NewTransactionController(svc).WithIdempotency(idem).WithAdminToken(adminToken).Register(r)
+	NewExportController(jobs).WithIdempotency(idem).Register(r)
+}
+
+// importPath: route upload CSV, lihat UploadRequestConfig.
+const importPath = "/v1/transactions/import"
+
+// UploadRequestConfig untuk fasthttp.Server.HeaderReceived: POST import
+// mendapat body limit & read timeout sendiri (dipilih setelah header dibaca,
+// sebelum body), route lain tetap memakai batas server.
+func UploadRequestConfig(bodyLimit int, readTimeout time.Duration) func(*fasthttp.RequestHeader) fasthttp.RequestConfig {
+	return func(h *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		path, _, _ := strings.Cut(string(h.RequestURI()), "?")
+		path = strings.TrimSuffix(path, "/")
+		if !h.IsPost() || !strings.EqualFold(path, importPath) {
+			return fasthttp.RequestConfig{}
+		}
+		return fasthttp.RequestConfig{MaxRequestBodySize: bodyLimit, ReadTimeout: readTimeout}
+	}
 }
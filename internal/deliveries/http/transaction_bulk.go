@@ -0,0 +1,109 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/middleware"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/audit"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- Bulk create
+
+const bulkTimeout = 2 * time.Minute
+
+// bulkCreate: POST /v1/transactions/bulk. Body array JSON atau NDJSON
+// (Content-Type application/x-ndjson / application/jsonl, satu transaksi per
+// baris). ?atomic=true => all-or-nothing, ?dry_run=true => tidak ada yang
+// disimpan (hasil per item tetap sama dengan request sungguhan).
+func (h *TransactionController) bulkCreate(c *fiber.Ctx) error {
+	items, err := parseBulkBody(c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	if len(items) == 0 {
+		return response.Error(c, fiber.StatusBadRequest, "bulk body is empty")
+	}
+	if len(items) > transaction.MaxBulkItems {
+		return response.Error(c, fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("too many items: %d (max %d)", len(items), transaction.MaxBulkItems))
+	}
+	ctx, cancel := context.WithTimeout(audit.NewContext(c.Context(), middleware.AuditInfo(c)), bulkTimeout)
+	defer cancel()
+
+	atomic := c.QueryBool("atomic")
+	var res transaction.BulkResult
+	if c.QueryBool("dry_run") {
+		// validasi, cek duplikat & insert tetap dijalankan, lalu di-rollback
+		err = h.svc.DryRun(ctx, func(svc transaction.Service) error {
+			var err error
+			res, err = svc.BulkCreate(ctx, items, atomic)
+			return err
+		})
+		res.Summary.DryRun = true
+	} else {
+		res, err = h.svc.BulkCreate(ctx, items, atomic)
+	}
+	if res.Incomplete() {
+		// sebagian sudah tersimpan: kirim hasil per item, bukan 500 polos
+		return response.ErrorWithData(c, fiber.StatusGatewayTimeout, "bulk_incomplete", res)
+	}
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	if res.Rejected() {
+		return response.ErrorWithData(c, fiber.StatusUnprocessableEntity, "bulk_rejected", res)
+	}
+	return response.Success(c, res.Items, res.Summary)
+}
+
+func parseBulkBody(c *fiber.Ctx) ([]transaction.CreateRequest, error) {
+	body := c.Body()
+	if isNDJSON(c.Get(fiber.HeaderContentType)) {
+		return parseNDJSON(body)
+	}
+	var items []transaction.CreateRequest
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("invalid_json: %w", err)
+	}
+	return items, nil
+}
+
+func isNDJSON(contentType string) bool {
+	ct, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	switch strings.TrimSpace(ct) {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
+
+// parseNDJSON: baris kosong dilewati; JSON rusak => error dengan nomor baris.
+func parseNDJSON(body []byte) ([]transaction.CreateRequest, error) {
+	var items []transaction.CreateRequest
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var item transaction.CreateRequest
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, fmt.Errorf("invalid_json at line %d: %w", line, err)
+		}
+		items = append(items, item)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("invalid_json: %w", err)
+	}
+	return items, nil
+}
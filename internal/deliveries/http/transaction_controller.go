@@ -1,19 +1,20 @@
 package http
 
 import (
-	"bytes"
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"math"
-	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
 	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
 	"github.com/aronipurwanto/go-download-csv/internal/middleware"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/audit"
 	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
 	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
 )
 
 // ---- config & keys
@@ -23,24 +24,51 @@ const (
 	updateLocalKey = "update_tx_body"
 
 	defaultTimeout = 5 * time.Second
+	exportTimeout  = 30 * time.Minute
+	snapshotTTL    = 24 * time.Hour
 	defaultPage    = 1
 	defaultSize    = 10
 	maxSize        = 100
+
+	// streaming export: flush tiap N baris & perpanjang write deadline koneksi
+	streamFlushRows    = 500
+	streamWriteTimeout = 30 * time.Second
 )
 
 // ---- controller
 
 type TransactionController struct {
-	svc     transaction.Service
-	timeout time.Duration
+	svc           transaction.Service
+	timeout       time.Duration
+	exportTimeout time.Duration
+	idempotent    fiber.Handler // Idempotency-Key untuk route yang mengubah data
+	idem          idempotency.Service
+	adminToken    string // header X-Admin-Token untuk purge; kosong = nonaktif
 }
 
 func NewTransactionController(svc transaction.Service) *TransactionController {
-	return &TransactionController{svc: svc, timeout: defaultTimeout}
+	return &TransactionController{svc: svc, timeout: defaultTimeout, exportTimeout: exportTimeout, idempotent: passThrough}
+}
+
+// WithIdempotency mengaktifkan header Idempotency-Key (lihat middleware.Idempotency).
+func (h *TransactionController) WithIdempotency(svc idempotency.Service) *TransactionController {
+	h.idem = svc
+	h.idempotent = middleware.Idempotency(svc, h.timeout)
+	return h
 }
 
+// idempotentFor: seperti h.idempotent untuk route dengan timeout sendiri
+// (bulk, import), supaya key tetap terkunci selama handler berjalan.
+func (h *TransactionController) idempotentFor(timeout time.Duration) fiber.Handler {
+	if h.idem == nil {
+		return passThrough
+	}
+	return middleware.Idempotency(h.idem, timeout)
+}
+
+// withCtx: context request dengan timeout + actor/request ID untuk history.
 func (h *TransactionController) withCtx(c *fiber.Ctx) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(c.Context(), h.timeout)
+	return context.WithTimeout(audit.NewContext(c.Context(), middleware.AuditInfo(c)), h.timeout)
 }
 
 // RegisterTransactionRoutes keeps backward-compatible signature
@@ -53,26 +81,61 @@ func (h *TransactionController) Register(r fiber.Router) {
 
 	// POST /v1/transactions
 	g.Post("/",
+		h.idempotent,
 		middleware.ValidateBody[transaction.CreateRequest](transaction.ValidateCreate, createLocalKey),
 		h.create,
 	)
 
+	// POST /v1/transactions/bulk: array JSON / NDJSON, hasil per item
+	g.Post("/bulk", h.idempotentFor(bulkTimeout), h.bulkCreate)
+
+	// POST /v1/transactions/import: multipart CSV (layout export), upsert by
+	// transaction_id; response = CSV laporan baris yang ditolak
+	g.Post("/import", h.idempotentFor(importTimeout), h.importCSV)
+
+	// GET /v1/transactions/export.csv (harus sebelum /:id agar tidak tertangkap sebagai id)
+	g.Get("/export.csv", h.export)
+	g.Get("/export.xlsx", h.exportXLSX)
+	g.Get("/export.ndjson", h.exportNDJSON)
+	g.Get("/export.jsonl", h.exportNDJSON)
+	g.Get("/export.parquet", h.exportParquet)
+
+	// GET /v1/transactions/search?q=
+	g.Get("/search", h.search)
+
+	// GET /v1/transactions/trash: transaksi yang di-soft-delete
+	g.Get("/trash", h.trash)
+
+	// GET /v1/transactions/:id/history (+ .csv): audit trail
+	g.Get("/:id/history.csv", h.historyCSV)
+	g.Get("/:id/history", h.history)
+
 	// GET /v1/transactions/:id
 	g.Get("/:id", h.getByID)
 
-	// GET /v1/transactions?page=1&size=10
+	// GET /v1/transactions?page=1&size=10 atau ?cursor=&size=10 (keyset)
 	g.Get("/", h.list)
 
 	// PUT /v1/transactions/:id
 	g.Put("/:id",
+		h.idempotent,
 		middleware.ValidateBody[transaction.UpdateRequest]((transaction.UpdateRequest).Validate, updateLocalKey),
 		h.update,
 	)
 
-	// DELETE /v1/transactions/:id
-	g.Delete("/:id", h.delete)
+	// POST /v1/transactions/:id/complete & /fail (body opsional: {"reason": "..."})
+	g.Post("/:id/complete", h.idempotent, h.complete)
+	g.Post("/:id/fail", h.idempotent, h.fail)
+
+	// POST /v1/transactions/:id/reverse & /refund => transaksi baru yang terhubung ke :id
+	g.Post("/:id/reverse", h.idempotent, h.reverse)
+	g.Post("/:id/refund", h.idempotent, h.refund)
+
+	// POST /v1/transactions/:id/restore: keluarkan dari trash
+	g.Post("/:id/restore", h.idempotent, h.restore)
 
-	g.Get("/export.csv", h.export) // <-- NEW
+	// DELETE /v1/transactions/:id (soft delete) atau ?hard=true (purge, admin)
+	g.Delete("/:id", h.idempotent, h.delete)
 }
 
 // ---- handlers
@@ -84,8 +147,9 @@ func (h *TransactionController) create(c *fiber.Ctx) error {
 
 	res, err := h.svc.Create(ctx, req)
 	if err != nil {
-		return response.Error(c, fiber.StatusBadRequest, err.Error())
+		return respondError(c, err, fiber.StatusBadRequest)
 	}
+	c.Set(fiber.HeaderETag, versionETag(res.Version))
 	return response.Created(c, res)
 }
 
@@ -96,17 +160,26 @@ func (h *TransactionController) getByID(c *fiber.Ctx) error {
 
 	res, err := h.svc.Get(ctx, id)
 	if err != nil {
-		return response.Error(c, fiber.StatusNotFound, err.Error())
+		return respondError(c, err, fiber.StatusInternalServerError)
 	}
+	c.Set(fiber.HeaderETag, versionETag(res.Version))
 	return response.Success(c, res, nil)
 }
 
 func (h *TransactionController) list(c *fiber.Ctx) error {
 	page, size := parsePagination(c)
+	f, err := parseFilter(c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	// ?cursor= (boleh kosong untuk halaman pertama) => keyset pagination
+	if c.Context().QueryArgs().Has("cursor") {
+		return h.listCursor(c, f, size)
+	}
 	ctx, cancel := h.withCtx(c)
 	defer cancel()
 
-	items, pageN, total, err := h.svc.List(ctx, page, size)
+	items, pageN, total, err := h.svc.List(ctx, f, page, size)
 	if err != nil {
 		return response.Error(c, fiber.StatusInternalServerError, err.Error())
 	}
@@ -114,21 +187,101 @@ func (h *TransactionController) list(c *fiber.Ctx) error {
 	return response.Success(c, items, meta)
 }
 
+// listCursor: keyset pagination urut (created_at, id) DESC. total hanya
+// dihitung jika ?with_total=true.
+func (h *TransactionController) listCursor(c *fiber.Ctx, f transaction.Filter, size int) error {
+	if f.Sort != "" {
+		return response.Error(c, fiber.StatusBadRequest, "sort is not supported with cursor pagination")
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.ListCursor(ctx, f, c.Query("cursor", ""), size, c.Query("with_total") == "true")
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	meta := fiber.Map{"size": size, "next_cursor": res.NextCursor, "has_more": res.NextCursor != ""}
+	if res.Total != nil {
+		meta["total"] = *res.Total
+	}
+	return response.Success(c, res.Items, meta)
+}
+
+// search: full-text search (prefix) diurutkan relevansi + highlight. Filter
+// lain tetap berlaku; sort diabaikan.
+func (h *TransactionController) search(c *fiber.Ctx) error {
+	page, size := parsePagination(c)
+	f, err := parseFilter(c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	if f.Query == "" {
+		return response.Error(c, fiber.StatusBadRequest, "q is required")
+	}
+	f.Sort = ""
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	items, total, err := h.svc.Search(ctx, f, page, size)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+	meta := fiber.Map{"page": page, "size": size, "total": total, "q": f.Query}
+	return response.Success(c, items, meta)
+}
+
+// update: If-Match (ETag dari GET) opsional; versi sudah berubah => 412.
 func (h *TransactionController) update(c *fiber.Ctx) error {
 	id := c.Params("id")
 	req := c.Locals(updateLocalKey).(transaction.UpdateRequest)
+	version, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
 
 	ctx, cancel := h.withCtx(c)
 	defer cancel()
 
-	res, err := h.svc.Update(ctx, id, req)
+	res, err := h.svc.Update(ctx, id, req, version)
 	if err != nil {
-		return response.Error(c, fiber.StatusBadRequest, err.Error())
+		return respondError(c, err, fiber.StatusBadRequest)
 	}
+	c.Set(fiber.HeaderETag, versionETag(res.Version))
+	return response.Success(c, res, nil)
+}
+
+func (h *TransactionController) complete(c *fiber.Ctx) error {
+	return h.transition(c, h.svc.Complete)
+}
+
+func (h *TransactionController) fail(c *fiber.Ctx) error {
+	return h.transition(c, h.svc.Fail)
+}
+
+// transition: body boleh kosong (reason opsional untuk complete).
+// Transisi ilegal => 409.
+func (h *TransactionController) transition(c *fiber.Ctx, fn func(context.Context, string, transaction.TransitionRequest) (transaction.Response, error)) error {
+	var req transaction.TransitionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.Error(c, fiber.StatusBadRequest, "invalid JSON body")
+		}
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := fn(ctx, c.Params("id"), req)
+	if err != nil {
+		return respondError(c, err, fiber.StatusBadRequest)
+	}
+	c.Set(fiber.HeaderETag, versionETag(res.Version))
 	return response.Success(c, res, nil)
 }
 
 func (h *TransactionController) delete(c *fiber.Ctx) error {
+	if c.Query("hard") == "true" {
+		return h.purge(c)
+	}
 	id := c.Params("id")
 	ctx, cancel := h.withCtx(c)
 	defer cancel()
@@ -139,8 +292,72 @@ func (h *TransactionController) delete(c *fiber.Ctx) error {
 	return response.Success(c, fiber.Map{"deleted": id}, nil)
 }
 
+func (h *TransactionController) reverse(c *fiber.Ctx) error {
+	var req transaction.ReverseRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.Error(c, fiber.StatusBadRequest, "invalid JSON body")
+		}
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.Reverse(ctx, c.Params("id"), req)
+	if err != nil {
+		return respondError(c, err, fiber.StatusBadRequest)
+	}
+	return response.Created(c, res)
+}
+
+func (h *TransactionController) refund(c *fiber.Ctx) error {
+	var req transaction.RefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "invalid JSON body")
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.Refund(ctx, c.Params("id"), req)
+	if err != nil {
+		return respondError(c, err, fiber.StatusBadRequest)
+	}
+	return response.Created(c, res)
+}
+
+// WithAdminToken mengaktifkan DELETE /:id?hard=true (purge) untuk request
+// dengan header X-Admin-Token yang cocok.
+func (h *TransactionController) WithAdminToken(token string) *TransactionController {
+	h.adminToken = token
+	return h
+}
+
 // ---- helpers
 
+func passThrough(c *fiber.Ctx) error { return c.Next() }
+
+// versionETag: ETag transaksi = version, mis. "3".
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch: kosong / "*" => 0 (tanpa syarat versi). Menerima juga
+// weak ETag (W/"3"); hanya satu ETag yang didukung.
+func parseIfMatch(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return 0, nil
+	}
+	raw := strings.TrimPrefix(s, "W/")
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return 0, errors.New(`invalid If-Match: expected an ETag such as "3"`)
+	}
+	v, err := strconv.ParseInt(raw[1:len(raw)-1], 10, 64)
+	if err != nil || v < 1 {
+		return 0, errors.New(`invalid If-Match: expected an ETag such as "3"`)
+	}
+	return v, nil
+}
+
 func parsePagination(c *fiber.Ctx) (int, int) {
 	page, _ := strconv.Atoi(c.Query("page", strconv.Itoa(defaultPage)))
 	size, _ := strconv.Atoi(c.Query("size", strconv.Itoa(defaultSize)))
@@ -158,222 +375,98 @@ func parsePagination(c *fiber.Ctx) (int, int) {
 }
 
 func (h *TransactionController) export(c *fiber.Ctx) error {
-	// --- parse filters from/to (YYYY-MM-DD atau RFC3339)
-	parseDate := func(s string) (time.Time, error) {
-		if s == "" {
-			return time.Time{}, nil
-		}
-		if len(s) == 10 {
-			return time.Parse("2006-01-02", s)
-		}
-		return time.Parse(time.RFC3339, s)
+	format, err := newCSVFormat(h, c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
 	}
-	from, _ := parseDate(c.Query("from", ""))
-	to, _ := parseDate(c.Query("to", ""))
-
-	// --- ambil semua data via pagination (tetap pakai Service.List)
-	ctx, cancel := h.withCtx(c)
-	defer cancel()
+	return h.runExport(c, format)
+}
 
-	page, size := 1, 500
-	var all []transaction.Response
-	for {
-		items, _, total, err := h.svc.List(ctx, page, size)
-		if err != nil {
-			return response.Error(c, fiber.StatusInternalServerError, err.Error())
-		}
-		for _, it := range items {
-			if (!from.IsZero() && it.TransactionDate.Before(from)) ||
-				(!to.IsZero() && it.TransactionDate.After(to)) {
-				continue
-			}
-			all = append(all, it)
-		}
-		if int64(page*size) >= total || len(items) == 0 {
-			break
-		}
-		page++
+// parseFilter membaca filter list & export dari query string.
+// from/to menerima YYYY-MM-DD atau RFC3339; "to" berformat tanggal saja
+// dianggap inklusif sampai akhir hari tersebut.
+func parseFilter(c *fiber.Ctx) (transaction.Filter, error) {
+	var (
+		f   transaction.Filter
+		err error
+	)
+	if f.From, err = parseDate(c.Query("from", ""), false); err != nil {
+		return f, fmt.Errorf("invalid from: %w", err)
 	}
-
-	// --- kalkulasi ukuran & jumlah part dengan memperhitungkan header per part
-	const chunkLimit = 10 * 1024 // 10KB
-	totalBytes := estimateCSVBytes(all)
-	headerBytes := estimateCSVHeaderBytes()
-	// tiap part akan memiliki header sendiri, jadi kira numParts dengan overhead header
-	numParts := int(math.Ceil((float64(totalBytes) + float64(headerBytes)) / (float64(chunkLimit) + float64(headerBytes))))
-	if numParts < 1 {
-		numParts = 1
+	if f.To, err = parseDate(c.Query("to", ""), true); err != nil {
+		return f, fmt.Errorf("invalid to: %w", err)
 	}
-
-	// --- jika user meminta bagian tertentu (?part=N) => stream CSV bagian N
-	if pStr := c.Query("part", ""); pStr != "" {
-		part, err := strconv.Atoi(pStr)
-		if err != nil || part < 1 || part > numParts {
-			return response.Error(c, fiber.StatusBadRequest, "invalid part")
-		}
-
-		start, end := splitRange(len(all), numParts, part)
-		fname := fmt.Sprintf("transactions_part_%d_of_%d.csv", part, numParts)
-		if !from.IsZero() || !to.IsZero() {
-			f := from.Format("2006-01-02")
-			t := to.Format("2006-01-02")
-			if f == "0001-01-01" {
-				f = "all"
-			}
-			if t == "0001-01-01" {
-				t = "all"
-			}
-			fname = fmt.Sprintf("transactions_%s_to_%s_part_%d_of_%d.csv", f, t, part, numParts)
-		}
-
-		// header download
-		c.Type("csv")                      // Content-Type: text/csv
-		c.Set("Cache-Control", "no-store") // jangan cache
-		c.Attachment(fname)
-
-		// optional: BOM untuk Excel Windows
-		if c.Query("excel") == "true" {
-			_, _ = c.Write([]byte{0xEF, 0xBB, 0xBF})
-		}
-
-		w := csv.NewWriter(c)
-		defer w.Flush()
-
-		if err := writeCSVHeader(w); err != nil {
-			return err
-		}
-		for _, it := range all[start:end] {
-			if err := writeCSVRow(w, it); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	// --- mode MANIFEST atau SINGLE
-	if numParts == 1 && totalBytes <= chunkLimit {
-		// kirim 1 file
-		fname := "transactions.csv"
-		if !from.IsZero() || !to.IsZero() {
-			f := from.Format("2006-01-02")
-			t := to.Format("2006-01-02")
-			if f == "0001-01-01" {
-				f = "all"
-			}
-			if t == "0001-01-01" {
-				t = "all"
+	if f.MinAmount, err = parseAmount(c.Query("min_amount", "")); err != nil {
+		return f, fmt.Errorf("invalid min_amount: %w", err)
+	}
+	if f.MaxAmount, err = parseAmount(c.Query("max_amount", "")); err != nil {
+		return f, fmt.Errorf("invalid max_amount: %w", err)
+	}
+	f.Status = c.Query("status", "")
+	f.Currency = c.Query("currency", "")
+	f.Method = c.Query("method", "")
+	f.OrderTypeCode = c.Query("order_type_code", "")
+	f.TransactionTypeCode = c.Query("transaction_type_code", "")
+	f.FromAccountNumber = c.Query("from_account_number", "")
+	f.ToAccountNumber = c.Query("to_account_number", "")
+	f.Sort = c.Query("sort", "")
+	f.Query = c.Query("q", "")
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		if path, ok := strings.CutPrefix(string(k), "meta."); ok {
+			if f.Meta == nil {
+				f.Meta = map[string]string{}
 			}
-			fname = "transactions_" + f + "_to_" + t + ".csv"
-		}
-		c.Type("csv")
-		c.Set("Cache-Control", "no-store")
-		c.Attachment(fname)
-
-		if c.Query("excel") == "true" {
-			_, _ = c.Write([]byte{0xEF, 0xBB, 0xBF})
+			f.Meta[path] = string(v)
 		}
+	})
+	return f, f.Validate()
+}
 
-		w := csv.NewWriter(c)
-		defer w.Flush()
-
-		if err := writeCSVHeader(w); err != nil {
-			return err
-		}
-		for _, it := range all {
-			if err := writeCSVRow(w, it); err != nil {
-				return err
-			}
-		}
-		return nil
+func parseAmount(s string) (*decimal.Decimal, error) {
+	if s == "" {
+		return nil, nil
 	}
-
-	// besar dari 10KB => bagi jadi beberapa link (manifest JSON)
-	base := c.BaseURL() + c.Path()
-	links := make([]string, 0, numParts)
-	for i := 1; i <= numParts; i++ {
-		q := url.Values{}
-		if !from.IsZero() {
-			q.Set("from", from.Format("2006-01-02"))
-		}
-		if !to.IsZero() {
-			q.Set("to", to.Format("2006-01-02"))
-		}
-		q.Set("part", strconv.Itoa(i))
-		// bawa flag excel jika ada
-		if c.Query("excel") == "true" {
-			q.Set("excel", "true")
-		}
-		links = append(links, base+"?"+q.Encode())
+	v, err := decimal.NewFromString(s)
+	if err != nil {
+		return nil, err
 	}
+	return &v, nil
+}
 
-	meta := fiber.Map{
-		"total_bytes_estimate": totalBytes,
-		"chunk_limit_bytes":    chunkLimit,
-		"num_parts":            numParts,
+func parseDate(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if len(s) == 10 {
+		t, err := time.Parse("2006-01-02", s)
+		if err == nil && endOfDay {
+			t = t.Add(24*time.Hour - time.Microsecond)
+		}
+		return t, err
 	}
-	return response.Success(c, fiber.Map{"links": links}, meta)
+	return time.Parse(time.RFC3339, s)
 }
 
-// hitung ukuran CSV dengan menulis ke buffer (estimasi akurat)
-func estimateCSVBytes(rows []transaction.Response) int {
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
-	_ = writeCSVHeader(w)
-	for _, it := range rows {
-		_ = writeCSVRow(w, it)
+func exportFileName(from, to time.Time, suffix, ext string) string {
+	if from.IsZero() && to.IsZero() {
+		return "transactions" + suffix + "." + ext
+	}
+	f, t := "all", "all"
+	if !from.IsZero() {
+		f = from.Format("2006-01-02")
 	}
-	w.Flush()
-	return buf.Len()
+	if !to.IsZero() {
+		t = to.Format("2006-01-02")
+	}
+	return "transactions_" + f + "_to_" + t + suffix + "." + ext
 }
 
-func writeCSVHeader(w *csv.Writer) error {
-	head := []string{
-		"Transaction ID",
-		"No Ref",
-		"Order Type Code",
-		"Order Type Name",
-		"Transaction Type Code",
-		"Transaction Type Name",
-		"Transaction Date",
-		"From Account Number",
-		"From Account Name",
-		"From Account Product Name",
-		"To Account Number",
-		"To Account Name",
-		"To Account Product Name",
-		"Amount",
-		"Status",
-		"Description",
-		"Method",
-		"Currency",
-		"Metadata",
-	}
-	return w.Write(head)
-}
+// countingWriter hanya menghitung byte yang ditulis (untuk estimasi ukuran).
+type countingWriter struct{ n int64 }
 
-func writeCSVRow(w *csv.Writer, it transaction.Response) error {
-	row := []string{
-		it.TransactionID,
-		it.NoRef,
-		it.OrderTypeCode,
-		it.OrderTypeName,
-		it.TransactionTypeCode,
-		it.TransactionTypeName,
-		it.TransactionDate.Format(time.RFC3339),
-		it.FromAccountNumber,
-		it.FromAccountName,
-		it.FromAccountProductName,
-		it.ToAccountNumber,
-		it.ToAccountName,
-		it.ToAccountProductName,
-		strconv.FormatFloat(it.Amount, 'f', -1, 64),
-		it.Status,
-		it.Description,
-		it.Method,
-		it.Currency,
-		string(it.Metadata),
-	}
-	return w.Write(row)
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
 }
 
 // bagi range data menjadi numParts bagian yang kira-kira sama rata
@@ -402,11 +495,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-func estimateCSVHeaderBytes() int {
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
-	_ = writeCSVHeader(w)
-	w.Flush()
-	return buf.Len()
-}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- Audit trail: GET /v1/transactions/:id/history(.csv)
+
+var historyCSVHeader = []string{
+	"Changed At", "Transaction ID", "Action", "Version", "Actor", "Request ID", "Field", "From", "To",
+}
+
+func (h *TransactionController) history(c *fiber.Ctx) error {
+	page, size := parsePagination(c)
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	items, total, err := h.svc.History(ctx, c.Params("id"), page, size)
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	meta := fiber.Map{"page": page, "size": size, "total": total}
+	return response.Success(c, items, meta)
+}
+
+// historyCSV: satu baris per field yang berubah (entri tanpa perubahan
+// tetap ditulis satu baris dengan Field kosong). ?excel=true menambah BOM.
+func (h *TransactionController) historyCSV(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+	// 404 sebelum header download dikirim
+	if _, _, err := h.svc.History(ctx, id, 1, 1); err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+
+	fname := "transaction_" + id + "_history.csv"
+	excel := c.Query("excel") == "true"
+	c.Type("csv")
+	c.Set("Cache-Control", "no-store")
+	c.Attachment(fname)
+	h.streamBody(c, fname, func(ctx context.Context, w io.Writer, flush func() error) error {
+		if excel {
+			if _, err := w.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+				return err
+			}
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(historyCSVHeader); err != nil {
+			return err
+		}
+		err := h.svc.ExportHistory(ctx, id, func(e transaction.HistoryResponse) error {
+			base := []string{
+				e.CreatedAt.Format(time.RFC3339Nano), e.TransactionID, e.Action,
+				strconv.FormatInt(e.Version, 10), e.Actor, e.RequestID,
+			}
+			fields := e.SortedFields()
+			if len(fields) == 0 {
+				return cw.Write(append(base, "", "", ""))
+			}
+			for _, f := range fields {
+				ch := e.Changes[f]
+				if err := cw.Write(append(base, f, historyValue(ch.From), historyValue(ch.To))); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+		return err
+	})
+	return nil
+}
+
+// historyValue: string JSON ditulis tanpa tanda kutip, null => kosong,
+// selain itu (angka, objek metadata) apa adanya.
+func historyValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
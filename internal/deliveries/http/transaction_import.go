@@ -0,0 +1,442 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/aronipurwanto/go-download-csv/internal/middleware"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/audit"
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+)
+
+// ---- Import CSV (kebalikan export.csv)
+//
+// Header memakai label atau key kolom export (exportColumnDefs). Kolom yang
+// hanya dibaca (status_reason, created_at, ...) diabaikan; kolom laporan
+// error (importLineLabel, importErrorLabel) juga, jadi laporan bisa
+// diperbaiki lalu di-upload ulang. parent_transaction_id, kind dan status
+// REVERSED tidak diabaikan: baris ditolak jika tidak cocok dengan transaksi
+// yang ada (reversal / refund hanya dibuat lewat endpoint-nya).
+
+const (
+	importTimeout   = 10 * time.Minute
+	importFormField = "file"
+
+	importLineLabel  = "Import Line"
+	importErrorLabel = "Import Error"
+)
+
+type importSetter func(r *transaction.UpsertRequest, v string) error
+
+func setText(field func(r *transaction.UpsertRequest) *string) importSetter {
+	return func(r *transaction.UpsertRequest, v string) error {
+		*field(r) = v
+		return nil
+	}
+}
+
+// setOptional: kolom ada di file => field terisi (walau kosong).
+func setOptional(field func(r *transaction.UpsertRequest) **string) importSetter {
+	return func(r *transaction.UpsertRequest, v string) error {
+		*field(r) = &v
+		return nil
+	}
+}
+
+// importFields: kolom export yang bisa diisi lewat import, per key.
+var importFields = map[string]importSetter{
+	"transaction_id":            setText(func(r *transaction.UpsertRequest) *string { return &r.TransactionID }),
+	"no_ref":                    setText(func(r *transaction.UpsertRequest) *string { return &r.NoRef }),
+	"order_type_code":           setText(func(r *transaction.UpsertRequest) *string { return &r.OrderTypeCode }),
+	"order_type_name":           setText(func(r *transaction.UpsertRequest) *string { return &r.OrderTypeName }),
+	"transaction_type_code":     setText(func(r *transaction.UpsertRequest) *string { return &r.TransactionTypeCode }),
+	"transaction_type_name":     setText(func(r *transaction.UpsertRequest) *string { return &r.TransactionTypeName }),
+	"from_account_number":       setText(func(r *transaction.UpsertRequest) *string { return &r.FromAccountNumber }),
+	"from_account_name":         setText(func(r *transaction.UpsertRequest) *string { return &r.FromAccountName }),
+	"from_account_product_name": setText(func(r *transaction.UpsertRequest) *string { return &r.FromAccountProductName }),
+	"to_account_number":         setText(func(r *transaction.UpsertRequest) *string { return &r.ToAccountNumber }),
+	"to_account_name":           setText(func(r *transaction.UpsertRequest) *string { return &r.ToAccountName }),
+	"to_account_product_name":   setText(func(r *transaction.UpsertRequest) *string { return &r.ToAccountProductName }),
+	"status":                    setText(func(r *transaction.UpsertRequest) *string { return &r.Status }),
+	"description":               setText(func(r *transaction.UpsertRequest) *string { return &r.Description }),
+	"method":                    setText(func(r *transaction.UpsertRequest) *string { return &r.Method }),
+	"currency":                  setText(func(r *transaction.UpsertRequest) *string { return &r.Currency }),
+	// parent_transaction_id & kind hanya dicocokkan (lihat transaction.UpsertRequest)
+	"parent_transaction_id": setOptional(func(r *transaction.UpsertRequest) **string { return &r.ParentTransactionID }),
+	"kind":                  setOptional(func(r *transaction.UpsertRequest) **string { return &r.Kind }),
+	"transaction_date": func(r *transaction.UpsertRequest, v string) error {
+		t, err := parseImportTime(v)
+		r.TransactionDate = t
+		return err
+	},
+	"amount": func(r *transaction.UpsertRequest, v string) error {
+		if v == "" {
+			return nil // ditolak ValidateCreate
+		}
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", v)
+		}
+		r.Amount = d
+		return nil
+	},
+	"metadata": func(r *transaction.UpsertRequest, v string) error {
+		if v == "" {
+			return nil
+		}
+		if !json.Valid([]byte(v)) {
+			return errors.New("metadata is not valid JSON")
+		}
+		r.Metadata = datatypes.JSON(v)
+		return nil
+	},
+}
+
+// importTimeLayouts: RFC3339 (format export) dulu, lalu format yang biasa
+// dihasilkan Excel / manual. Tanpa zona => zona waktu server.
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseImportTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil // ditolak ValidateCreate
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid transaction_date %q (use RFC3339 or YYYY-MM-DD[ HH:MM:SS])", v)
+}
+
+// importColumn: satu kolom file. set nil = kolom read-only export (diabaikan);
+// report = kolom laporan error (tidak ikut ditulis ulang ke laporan).
+type importColumn struct {
+	set    importSetter
+	report bool
+}
+
+// parseImportHeader memetakan header ke importFields. Kolom tidak dikenal
+// ditolak (kemungkinan salah ketik) dan kolom wajib CreateRequest harus ada.
+func parseImportHeader(header []string) ([]importColumn, error) {
+	cols := make([]importColumn, len(header))
+	seen := map[string]bool{}
+	for i, h := range header {
+		if h == importLineLabel || h == importErrorLabel {
+			cols[i].report = true
+			continue
+		}
+		col, ok := findImportColumn(h)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		if seen[col.Key] {
+			return nil, fmt.Errorf("duplicate column %q", h)
+		}
+		seen[col.Key] = true
+		cols[i].set = importFields[col.Key]
+	}
+	var missing []string
+	for _, key := range importRequiredColumns {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+// importRequiredColumns: field wajib CreateRequest (transaction_id boleh
+// tidak ada => transaksi baru dengan UUIDv7).
+var importRequiredColumns = []string{
+	"order_type_code", "order_type_name", "transaction_type_code", "transaction_type_name",
+	"transaction_date", "from_account_number", "from_account_name", "from_account_product_name",
+	"to_account_number", "to_account_name", "to_account_product_name",
+	"amount", "status", "method", "currency",
+}
+
+func findImportColumn(h string) (exportColumn, bool) {
+	for _, col := range exportColumnDefs {
+		if strings.EqualFold(h, col.Label) || strings.EqualFold(h, col.Key) {
+			return col, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+func parseImportRecord(cols []importColumn, record []string) (transaction.UpsertRequest, error) {
+	var req transaction.UpsertRequest
+	for i, col := range cols {
+		if col.set == nil {
+			continue
+		}
+		if err := col.set(&req, strings.TrimSpace(record[i])); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// importSummary: di header X-Import-* (laporan CSV) atau body (dry run).
+type importSummary struct {
+	Total     int `json:"total"`
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Rejected  int `json:"rejected"`
+}
+
+func (s importSummary) setHeaders(c *fiber.Ctx) {
+	c.Set("X-Import-Total", strconv.Itoa(s.Total))
+	c.Set("X-Import-Created", strconv.Itoa(s.Created))
+	c.Set("X-Import-Updated", strconv.Itoa(s.Updated))
+	c.Set("X-Import-Unchanged", strconv.Itoa(s.Unchanged))
+	c.Set("X-Import-Rejected", strconv.Itoa(s.Rejected))
+}
+
+// importCSV: POST /v1/transactions/import (multipart, field "file"). Tiap baris
+// di-upsert by transaction_id; response = CSV laporan baris yang ditolak
+// (hanya header jika semua berhasil), ringkasan di header X-Import-*.
+// ?excel=true menambah BOM di laporan, ?dry_run=true lihat importDryRun.
+func (h *TransactionController) importCSV(c *fiber.Ctx) error {
+	fh, err := c.FormFile(importFormField)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "multipart field \""+importFormField+"\" (CSV file) is required")
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1 // jumlah kolom dicek per baris, supaya masuk laporan
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return response.Error(c, fiber.StatusBadRequest, "csv file is empty")
+	}
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "invalid csv header: "+err.Error())
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // BOM Excel
+	cols, err := parseImportHeader(header)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(audit.NewContext(c.Context(), middleware.AuditInfo(c)), importTimeout)
+	defer cancel()
+
+	if c.QueryBool("dry_run") {
+		return h.importDryRun(ctx, c, r, cols)
+	}
+
+	var report bytes.Buffer
+	if c.Query("excel") == "true" {
+		report.Write([]byte{0xEF, 0xBB, 0xBF})
+	}
+	rw := csv.NewWriter(&report)
+	if err := rw.Write(reportRow(cols, importLineLabel, importErrorLabel, header)); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+	sum, err := runImport(ctx, h.svc, r, cols, func(row importRow) error {
+		if row.err == nil {
+			return nil
+		}
+		return rw.Write(reportRow(cols, strconv.Itoa(row.line), row.err.Error(), row.record))
+	})
+	if err != nil {
+		return importError(ctx, c, sum, err)
+	}
+	rw.Flush()
+	if err := rw.Error(); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	sum.setHeaders(c)
+	middleware.MarkEnveloped(c) // body CSV, bukan JSON
+	c.Attachment("import_errors.csv")
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	return c.Send(report.Bytes())
+}
+
+// ---- Dry run (?dry_run=true)
+
+const (
+	defaultDryRunSample = 20
+	maxDryRunSample     = 100
+)
+
+type importDiff struct {
+	Line          int                                `json:"line"`
+	TransactionID string                             `json:"transaction_id"`
+	Changes       map[string]transaction.FieldChange `json:"changes"`
+}
+
+type importRejection struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type importDryRunResult struct {
+	DryRun   bool              `json:"dry_run"`
+	Summary  importSummary     `json:"summary"`
+	Diffs    []importDiff      `json:"diffs"`    // contoh baris updated
+	Rejected []importRejection `json:"rejected"` // contoh baris ditolak
+}
+
+// importDryRun: seluruh import dijalankan di transaksi DB yang di-rollback
+// (lihat transaction.Service.DryRun). Response JSON: ringkasan + contoh diff
+// terhadap baris yang sudah ada dan contoh baris ditolak, masing-masing
+// maksimal ?sample= (default 20, maks 100).
+func (h *TransactionController) importDryRun(ctx context.Context, c *fiber.Ctx, r *csv.Reader, cols []importColumn) error {
+	sample := min(max(c.QueryInt("sample", defaultDryRunSample), 0), maxDryRunSample)
+	out := importDryRunResult{DryRun: true, Diffs: []importDiff{}, Rejected: []importRejection{}}
+	err := h.svc.DryRun(ctx, func(svc transaction.Service) error {
+		var err error
+		out.Summary, err = runImport(ctx, svc, r, cols, func(row importRow) error {
+			switch {
+			case row.err != nil && len(out.Rejected) < sample:
+				out.Rejected = append(out.Rejected, importRejection{Line: row.line, Error: row.err.Error()})
+			case row.result.Action == transaction.UpsertUpdated && len(out.Diffs) < sample:
+				out.Diffs = append(out.Diffs, importDiff{
+					Line:          row.line,
+					TransactionID: row.result.Transaction.TransactionID,
+					Changes:       row.result.Changes,
+				})
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return importError(ctx, c, out.Summary, err)
+	}
+	return response.Success(c, out, nil)
+}
+
+// ---- baris
+
+// importRow: hasil satu baris data; err != nil => ditolak.
+type importRow struct {
+	line   int
+	record []string
+	result transaction.UpsertResult
+	err    error
+}
+
+// runImport meng-upsert seluruh baris r lewat svc dan memanggil onRow untuk
+// tiap baris. Error reader, ctx atau onRow menghentikan import.
+func runImport(ctx context.Context, svc transaction.Service, r *csv.Reader, cols []importColumn, onRow func(importRow) error) (importSummary, error) {
+	var sum importSummary
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return sum, nil
+		}
+		row := importRow{record: record}
+		var perr *csv.ParseError
+		switch {
+		case errors.As(err, &perr):
+			row.line, row.err = perr.StartLine, perr.Err
+		case err != nil:
+			return sum, fmt.Errorf("%w: %v", errImportRead, err)
+		case isBlankRecord(record):
+			continue
+		default:
+			row.line, _ = r.FieldPos(0)
+			row.result, row.err = upsertRecord(ctx, svc, cols, record)
+		}
+		if err := ctx.Err(); err != nil {
+			return sum, err
+		}
+
+		sum.Total++
+		switch {
+		case row.err != nil:
+			sum.Rejected++
+		case row.result.Action == transaction.UpsertCreated:
+			sum.Created++
+		case row.result.Action == transaction.UpsertUpdated:
+			sum.Updated++
+		default:
+			sum.Unchanged++
+		}
+		if err := onRow(row); err != nil {
+			return sum, err
+		}
+	}
+}
+
+func upsertRecord(ctx context.Context, svc transaction.Service, cols []importColumn, record []string) (transaction.UpsertResult, error) {
+	if len(record) != len(cols) {
+		return transaction.UpsertResult{}, fmt.Errorf("expected %d fields, got %d", len(cols), len(record))
+	}
+	req, err := parseImportRecord(cols, record)
+	if err != nil {
+		return transaction.UpsertResult{}, err
+	}
+	return svc.Upsert(ctx, req)
+}
+
+// errImportRead: file rusak di tengah (bukan error per baris).
+var errImportRead = errors.New("invalid csv")
+
+// importError: import berhenti di tengah. Tanpa dry run, baris sebelumnya
+// sudah tersimpan; jumlahnya disebut di pesan.
+func importError(ctx context.Context, c *fiber.Ctx, sum importSummary, err error) error {
+	switch {
+	case ctx.Err() != nil:
+		return response.Error(c, fiber.StatusGatewayTimeout, fmt.Sprintf("import timed out after %d rows", sum.Total))
+	case errors.Is(err, errImportRead):
+		return response.Error(c, fiber.StatusBadRequest, fmt.Sprintf("import stopped after %d rows: %v", sum.Total, err))
+	}
+	return response.Error(c, fiber.StatusInternalServerError, fmt.Sprintf("import stopped after %d rows: %v", sum.Total, err))
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// reportRow: baris laporan = line, error, lalu kolom asli tanpa kolom laporan
+// lama. Record dengan jumlah kolom salah ditulis apa adanya.
+func reportRow(cols []importColumn, line, reason string, record []string) []string {
+	out := append(make([]string, 0, len(record)+2), line, reason)
+	if len(record) != len(cols) {
+		return append(out, record...)
+	}
+	for i, v := range record {
+		if !cols[i].report {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// importHeader: kolom wajib + beberapa kolom opsional, campuran label & key.
+var importHeader = []string{
+	"Transaction ID", "order_type_code", "Order Type Name", "Transaction Type Code", "Transaction Type Name",
+	"Transaction Date", "From Account Number", "From Account Name", "From Account Product Name",
+	"To Account Number", "To Account Name", "To Account Product Name",
+	"Amount", "Status", "Method", "Currency", "Metadata", "Created At",
+}
+
+func importRecord(txID, date, amount, metadata string) []string {
+	return []string{
+		txID, "ORD", "Order", "TRF", "Transfer",
+		date, "111", "Budi", "Tabungan",
+		"222", "Sari", "Giro",
+		amount, "SUCCESS", "TRANSFER", "IDR", metadata, "2025-01-01T00:00:00Z",
+	}
+}
+
+func TestParseImportHeader(t *testing.T) {
+	withReport := append([]string{importLineLabel, importErrorLabel}, importHeader...)
+	tests := []struct {
+		name    string
+		header  []string
+		wantErr string
+	}{
+		{"labels and keys", importHeader, ""},
+		{"case insensitive", append([]string{"TRANSACTION_ID"}, importHeader[1:]...), ""},
+		{"error report re-upload", withReport, ""},
+		{"with parent and kind", append(append([]string{}, importHeader...), "Parent Transaction ID", "kind"), ""},
+		{"unknown column", append(append([]string{}, importHeader...), "Amout"), `unknown column "Amout"`},
+		{"duplicate column", append(append([]string{}, importHeader...), "amount"), `duplicate column "amount"`},
+		{"missing columns", importHeader[2:], "missing columns: order_type_code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, err := parseImportHeader(tt.header)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cols) != len(tt.header) {
+				t.Fatalf("got %d columns, want %d", len(cols), len(tt.header))
+			}
+			for i, h := range tt.header {
+				report := h == importLineLabel || h == importErrorLabel
+				readOnly := strings.EqualFold(h, "Created At")
+				if cols[i].report != report || (cols[i].set == nil) != (report || readOnly) {
+					t.Fatalf("column %q = %+v", h, cols[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseImportTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2025-01-02T03:04:05Z", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"2025-01-02T03:04:05.123+07:00", time.Date(2025, 1, 1, 20, 4, 5, 123e6, time.UTC), false},
+		{"2025-01-02T03:04:05", time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local), false},
+		{"2025-01-02 03:04:05", time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local), false},
+		{"2025-01-02 03:04", time.Date(2025, 1, 2, 3, 4, 0, 0, time.Local), false},
+		{"2025-01-02", time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{"02/01/2025", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseImportTime(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseImportTime(%q) err = %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseImportTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseImportRecord(t *testing.T) {
+	header := append(append([]string{}, importHeader...), "parent_transaction_id", "kind")
+	cols, err := parseImportHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(amount, metadata, parent string) []string {
+		return append(importRecord("tx-1", "2025-01-02", amount, metadata), parent, "")
+	}
+	tests := []struct {
+		name    string
+		record  []string
+		wantErr string
+		check   func(t *testing.T, r transaction.UpsertRequest)
+	}{
+		{"full row", record(" 150000.00 ", `{"a":1}`, ""), "", func(t *testing.T, r transaction.UpsertRequest) {
+			if r.TransactionID != "tx-1" || r.Status != transaction.StatusSuccess || r.Currency != "IDR" {
+				t.Fatalf("req = %+v", r)
+			}
+			if !r.Amount.Equal(decimal.NewFromInt(150000)) {
+				t.Fatalf("amount = %s", r.Amount)
+			}
+			if string(r.Metadata) != `{"a":1}` {
+				t.Fatalf("metadata = %s", r.Metadata)
+			}
+			// kolom ada tapi kosong => pointer ke "" (bukan nil)
+			if r.ParentTransactionID == nil || *r.ParentTransactionID != "" || r.Kind == nil || *r.Kind != "" {
+				t.Fatalf("parent/kind = %v/%v", r.ParentTransactionID, r.Kind)
+			}
+		}},
+		{"parent set", record("1", "", "tx-0"), "", func(t *testing.T, r transaction.UpsertRequest) {
+			if r.ParentTransactionID == nil || *r.ParentTransactionID != "tx-0" {
+				t.Fatalf("parent = %v", r.ParentTransactionID)
+			}
+			if r.Metadata != nil {
+				t.Fatalf("metadata = %s", r.Metadata)
+			}
+		}},
+		{"empty amount", record("", "", ""), "", func(t *testing.T, r transaction.UpsertRequest) {
+			if !r.Amount.IsZero() {
+				t.Fatalf("amount = %s", r.Amount)
+			}
+		}},
+		{"bad amount", record("1.000,50", "", ""), `invalid amount "1.000,50"`, nil},
+		{"bad metadata", record("1", "{a:1}", ""), "metadata is not valid JSON", nil},
+		{"bad date", func() []string {
+			r := record("1", "", "")
+			r[5] = "kemarin"
+			return r
+		}(), "invalid transaction_date", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseImportRecord(cols, tt.record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+// importService: Upsert hasil tetap per transaction_id, sisanya tidak dipakai.
+type importService struct {
+	transaction.Service
+	results map[string]transaction.UpsertResult
+	errs    map[string]error
+	calls   []transaction.UpsertRequest
+}
+
+func (s *importService) Upsert(_ context.Context, in transaction.UpsertRequest) (transaction.UpsertResult, error) {
+	s.calls = append(s.calls, in)
+	return s.results[in.TransactionID], s.errs[in.TransactionID]
+}
+
+func TestRunImport(t *testing.T) {
+	svc := &importService{
+		results: map[string]transaction.UpsertResult{
+			"tx-new":  {Action: transaction.UpsertCreated},
+			"tx-upd":  {Action: transaction.UpsertUpdated},
+			"tx-same": {Action: transaction.UpsertUnchanged},
+		},
+		errs: map[string]error{"tx-dup": &transaction.DuplicateError{Field: "no_ref", ConflictID: "tx-old"}},
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	_ = w.Write(importHeader)
+	_ = w.Write(importRecord("tx-new", "2025-01-02", "1000", ""))
+	_ = w.Write(make([]string, len(importHeader))) // baris kosong dilewati
+	_ = w.Write(importRecord("tx-upd", "2025-01-02", "1000", ""))
+	_ = w.Write(importRecord("tx-same", "2025-01-02", "1000", ""))
+	_ = w.Write(importRecord("tx-bad", "2025-01-02", "abc", ""))
+	_ = w.Write(importRecord("tx-dup", "2025-01-02", "1000", ""))
+	_ = w.Write([]string{"tx-short", "ORD"})
+	w.Flush()
+
+	r := csv.NewReader(strings.NewReader(b.String()))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := parseImportHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type rowResult struct {
+		line int
+		err  string
+	}
+	var rows []rowResult
+	sum, err := runImport(context.Background(), svc, r, cols, func(row importRow) error {
+		res := rowResult{line: row.line}
+		if row.err != nil {
+			res.err = row.err.Error()
+		}
+		rows = append(rows, res)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := importSummary{Total: 6, Created: 1, Updated: 1, Unchanged: 1, Rejected: 3}
+	if sum != want {
+		t.Fatalf("summary = %+v, want %+v", sum, want)
+	}
+	wantRows := []rowResult{
+		{2, ""}, {4, ""}, {5, ""},
+		{6, `invalid amount "abc"`},
+		{7, "duplicate_no_ref: already used by transaction tx-old"},
+		{8, "expected 18 fields, got 2"},
+	}
+	if len(rows) != len(wantRows) {
+		t.Fatalf("rows = %+v", rows)
+	}
+	for i, w := range wantRows {
+		if rows[i].line != w.line || !strings.Contains(rows[i].err, w.err) || (w.err == "") != (rows[i].err == "") {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], w)
+		}
+	}
+	// baris rusak tidak sampai ke service
+	if len(svc.calls) != 4 {
+		t.Fatalf("Upsert called %d times, want 4", len(svc.calls))
+	}
+}
+
+func TestRunImportStopsOnRowError(t *testing.T) {
+	svc := &importService{results: map[string]transaction.UpsertResult{}}
+	cols, err := parseImportHeader(importHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	for _, id := range []string{"a", "b", "c"} {
+		_ = w.Write(importRecord(id, "2025-01-02", "1", ""))
+	}
+	w.Flush()
+
+	stop := errors.New("client gone")
+	sum, err := runImport(context.Background(), svc, csv.NewReader(strings.NewReader(b.String())), cols,
+		func(row importRow) error {
+			if row.record[0] == "b" {
+				return stop
+			}
+			return nil
+		})
+	if !errors.Is(err, stop) || sum.Total != 2 {
+		t.Fatalf("sum = %+v, err = %v", sum, err)
+	}
+}
+
+func TestReportRow(t *testing.T) {
+	cols, err := parseImportHeader(append([]string{importLineLabel, importErrorLabel}, importHeader...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := append([]string{"3", "old error"}, importRecord("tx-1", "2025-01-02", "1", "")...)
+	got := reportRow(cols, "7", "new error", old)
+	if len(got) != len(importHeader)+2 || got[0] != "7" || got[1] != "new error" || got[2] != "tx-1" {
+		t.Fatalf("reportRow = %v", got)
+	}
+	short := reportRow(cols, "8", "bad", []string{"x"})
+	if strings.Join(short, ",") != "8,bad,x" {
+		t.Fatalf("reportRow short = %v", short)
+	}
+}
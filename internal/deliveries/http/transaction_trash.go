@@ -0,0 +1,61 @@
+package http
+
+import (
+	"crypto/subtle"
+
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/response"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ---- Trash: soft delete, restore & purge
+
+const headerAdminToken = "X-Admin-Token"
+
+// trash: filter & sort sama dengan list; default urut deleted_at terbaru.
+func (h *TransactionController) trash(c *fiber.Ctx) error {
+	page, size := parsePagination(c)
+	f, err := parseFilter(c)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, err.Error())
+	}
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	items, total, err := h.svc.Trash(ctx, f, page, size)
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	meta := fiber.Map{"page": page, "size": size, "total": total}
+	return response.Success(c, items, meta)
+}
+
+func (h *TransactionController) restore(c *fiber.Ctx) error {
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	res, err := h.svc.Restore(ctx, c.Params("id"))
+	if err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	c.Set(fiber.HeaderETag, versionETag(res.Version))
+	return response.Success(c, res, nil)
+}
+
+// purge: DELETE /:id?hard=true. Hanya admin (X-Admin-Token) dan hanya untuk
+// transaksi yang sudah di trash melewati masa retensi.
+func (h *TransactionController) purge(c *fiber.Ctx) error {
+	if h.adminToken == "" {
+		return response.Error(c, fiber.StatusForbidden, "hard delete is disabled (ADMIN_TOKEN not set)")
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Get(headerAdminToken)), []byte(h.adminToken)) != 1 {
+		return response.Error(c, fiber.StatusForbidden, "admin token required")
+	}
+	id := c.Params("id")
+	ctx, cancel := h.withCtx(c)
+	defer cancel()
+
+	if err := h.svc.Purge(ctx, id); err != nil {
+		return respondError(c, err, fiber.StatusInternalServerError)
+	}
+	return response.Success(c, fiber.Map{"purged": id}, nil)
+}
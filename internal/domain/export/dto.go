@@ -0,0 +1,74 @@
+package export
+
+import (
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/go-playground/validator/v10"
+)
+
+// Options mengatur bentuk file hasil export.
+type Options struct {
+	Excel   bool   `json:"excel"`             // tambahkan UTF-8 BOM untuk Excel Windows
+	Columns string `json:"columns,omitempty"` // sama dengan ?columns= (csv)
+	Preset  string `json:"preset,omitempty"`  // sama dengan ?preset= (csv)
+}
+
+type CreateRequest struct {
+	Format  string             `json:"format" validate:"omitempty,oneof=csv ndjson parquet"`
+	Filter  transaction.Filter `json:"filter"`
+	Options Options            `json:"options"`
+}
+
+type Response struct {
+	ID          string             `json:"id"`
+	Status      string             `json:"status"`
+	Format      string             `json:"format"`
+	Filter      transaction.Filter `json:"filter"`
+	Options     Options            `json:"options"`
+	RowsTotal   int64              `json:"rows_total"`
+	RowsWritten int64              `json:"rows_written"`
+	Progress    float64            `json:"progress"` // 0..100
+	FileName    string             `json:"file_name,omitempty"`
+	FileSize    int64              `json:"file_size,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+func ToResponse(j *Job) Response {
+	f, _ := j.DecodeFilter()
+	o, _ := j.DecodeOptions()
+	res := Response{
+		ID:          j.ID,
+		Status:      j.Status,
+		Format:      j.Format,
+		Filter:      f,
+		Options:     o,
+		RowsTotal:   j.RowsTotal,
+		RowsWritten: j.RowsWritten,
+		Error:       j.Error,
+		StartedAt:   j.StartedAt,
+		FinishedAt:  j.FinishedAt,
+		CreatedAt:   j.CreatedAt,
+	}
+	switch {
+	case j.Status == StatusDone:
+		res.Progress = 100
+		res.FileName = j.FileName
+		res.FileSize = j.FileSize
+	case j.RowsTotal > 0:
+		res.Progress = float64(j.RowsWritten) * 100 / float64(j.RowsTotal)
+	}
+	return res
+}
+
+var validate = validator.New()
+
+func ValidateCreate(r CreateRequest) error {
+	if err := validate.Struct(r); err != nil {
+		return err
+	}
+	return r.Filter.Validate()
+}
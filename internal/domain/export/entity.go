@@ -0,0 +1,66 @@
+package export
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"gorm.io/datatypes"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+	StatusExpired = "expired" // file dihapus setelah Config.Retention
+)
+
+// Job adalah satu permintaan export asinkron. State disimpan di Postgres
+// supaya antrian tetap jalan setelah aplikasi restart.
+type Job struct {
+	ID          string         `gorm:"primaryKey;size:36" json:"id"`
+	Status      string         `gorm:"size:16;index" json:"status"`
+	Format      string         `gorm:"size:16" json:"format"`
+	Filter      datatypes.JSON `json:"filter"`
+	Options     datatypes.JSON `json:"options"`
+	RowsTotal   int64          `json:"rows_total"`
+	RowsWritten int64          `json:"rows_written"`
+	FileName    string         `gorm:"size:255" json:"file_name"`
+	FilePath    string         `gorm:"size:512" json:"-"`
+	FileSize    int64          `json:"file_size"`
+	Error       string         `gorm:"size:1024" json:"error,omitempty"`
+
+	// Owner: instance yang sedang menjalankan job; HeartbeatAt diperbarui
+	// berkala selama running. Job running dengan heartbeat basi dianggap
+	// yatim (instance mati) dan dikembalikan ke antrian.
+	Owner       string     `gorm:"size:64" json:"-"`
+	HeartbeatAt *time.Time `gorm:"index" json:"-"`
+
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Job) TableName() string { return "export_jobs" }
+
+// DecodeFilter mengembalikan filter transaksi yang disimpan di job.
+func (j *Job) DecodeFilter() (transaction.Filter, error) {
+	var f transaction.Filter
+	if len(j.Filter) == 0 {
+		return f, nil
+	}
+	err := json.Unmarshal(j.Filter, &f)
+	return f, err
+}
+
+// DecodeOptions mengembalikan opsi export yang disimpan di job.
+func (j *Job) DecodeOptions() (Options, error) {
+	var o Options
+	if len(j.Options) == 0 {
+		return o, nil
+	}
+	err := json.Unmarshal(j.Options, &o)
+	return o, err
+}
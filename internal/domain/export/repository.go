@@ -0,0 +1,34 @@
+package export
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, j *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	ListByStatus(ctx context.Context, status string, limit int) ([]Job, error)
+
+	// Claim memindahkan job queued => running milik owner secara atomik;
+	// false jika job sudah diambil worker lain.
+	Claim(ctx context.Context, id, owner string) (bool, error)
+	// Method di bawah ini hanya mengubah job running milik owner; jika job
+	// sudah di-requeue (lihat RequeueStale) hasilnya ErrLeaseLost.
+
+	// Heartbeat menandai job running milik owner masih hidup.
+	Heartbeat(ctx context.Context, id, owner string) error
+	SetTotal(ctx context.Context, id, owner string, total int64) error
+	SetProgress(ctx context.Context, id, owner string, rows int64) error
+	MarkDone(ctx context.Context, id, owner string, rows int64, path string, size int64) error
+	MarkFailed(ctx context.Context, id, owner string, reason string) error
+
+	// RequeueStale mengembalikan job running yang heartbeat-nya sebelum
+	// staleBefore (instance pemiliknya mati) ke antrian. Job instance lain
+	// yang masih hidup tidak tersentuh.
+	RequeueStale(ctx context.Context, staleBefore time.Time) (int64, error)
+
+	// ListExpired: job done yang selesai sebelum before (retensi habis).
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]Job, error)
+	MarkExpired(ctx context.Context, id string) error
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormRepository struct{ db *gorm.DB }
+
+func NewGormRepository(db *gorm.DB) Repository { return &gormRepository{db: db} }
+
+func (r *gormRepository) Create(ctx context.Context, j *Job) error {
+	return r.db.WithContext(ctx).Create(j).Error
+}
+
+func (r *gormRepository) Get(ctx context.Context, id string) (*Job, error) {
+	var out Job
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&out).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *gormRepository) ListByStatus(ctx context.Context, status string, limit int) ([]Job, error) {
+	var items []Job
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (r *gormRepository) Claim(ctx context.Context, id, owner string) (bool, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusQueued).
+		Updates(map[string]any{
+			"status":       StatusRunning,
+			"started_at":   now,
+			"rows_written": 0,
+			"owner":        owner,
+			"heartbeat_at": now,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// owned: job running yang masih dipegang owner. 0 baris => ErrLeaseLost
+// (job sudah di-requeue dan mungkin diambil worker lain).
+func (r *gormRepository) owned(ctx context.Context, id, owner string, values map[string]any) error {
+	res := r.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND owner = ? AND status = ?", id, owner, StatusRunning).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *gormRepository) Heartbeat(ctx context.Context, id, owner string) error {
+	return r.owned(ctx, id, owner, map[string]any{"heartbeat_at": time.Now()})
+}
+
+func (r *gormRepository) SetTotal(ctx context.Context, id, owner string, total int64) error {
+	return r.owned(ctx, id, owner, map[string]any{"rows_total": total})
+}
+
+func (r *gormRepository) SetProgress(ctx context.Context, id, owner string, rows int64) error {
+	return r.owned(ctx, id, owner, map[string]any{"rows_written": rows})
+}
+
+func (r *gormRepository) MarkDone(ctx context.Context, id, owner string, rows int64, path string, size int64) error {
+	return r.owned(ctx, id, owner, map[string]any{
+		"status":       StatusDone,
+		"rows_written": rows,
+		"file_path":    path,
+		"file_size":    size,
+		"finished_at":  time.Now(),
+	})
+}
+
+func (r *gormRepository) MarkFailed(ctx context.Context, id, owner string, reason string) error {
+	return r.owned(ctx, id, owner, map[string]any{
+		"status":      StatusFailed,
+		"error":       truncateRunes(reason, maxErrorLen),
+		"finished_at": time.Now(),
+	})
+}
+
+func (r *gormRepository) RequeueStale(ctx context.Context, staleBefore time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&Job{}).
+		Where("status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", StatusRunning, staleBefore).
+		Updates(map[string]any{"status": StatusQueued, "started_at": nil, "rows_written": 0, "owner": "", "heartbeat_at": nil})
+	return res.RowsAffected, res.Error
+}
+
+func (r *gormRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]Job, error) {
+	var items []Job
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND finished_at < ?", StatusDone, before).
+		Order("finished_at ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (r *gormRepository) MarkExpired(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusDone).
+		Updates(map[string]any{"status": StatusExpired, "file_path": "", "file_size": 0}).Error
+}
+
+// maxErrorLen: ukuran kolom Job.Error (karakter).
+const maxErrorLen = 1024
+
+// truncateRunes memotong s menjadi maksimal n karakter tanpa memecah UTF-8.
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
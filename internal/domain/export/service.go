@@ -0,0 +1,388 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/transaction"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound = errors.New("not_found")
+	ErrNotReady = errors.New("export_not_ready")
+	ErrExpired  = errors.New("export_expired") // file sudah dihapus (retensi)
+	// ErrLeaseLost: job di-requeue (heartbeat basi) saat worker ini masih
+	// mengerjakannya; hasil worker ini dibuang.
+	ErrLeaseLost = errors.New("export_lease_lost")
+)
+
+// Renderer menulis isi file export untuk satu job. Diimplementasikan oleh
+// layer delivery supaya format file sama dengan endpoint export sinkron.
+type Renderer interface {
+	// Render menulis seluruh baris ke w dan memanggil progress secara berkala
+	// dengan jumlah baris yang sudah ditulis.
+	Render(ctx context.Context, job *Job, w io.Writer, progress func(rows int64)) (int64, error)
+	// FileName adalah nama file yang dipakai saat download.
+	FileName(job *Job) string
+}
+
+type Service interface {
+	Enqueue(ctx context.Context, in CreateRequest) (Response, error)
+	Get(ctx context.Context, id string) (Response, error)
+	// Artifact mengembalikan path file di disk & nama file untuk download.
+	Artifact(ctx context.Context, id string) (path, name string, err error)
+
+	// Start menjalankan worker pool sampai ctx dibatalkan.
+	Start(ctx context.Context)
+}
+
+type Config struct {
+	Workers      int
+	QueueSize    int
+	JobTimeout   time.Duration
+	PollInterval time.Duration
+	// Retention: file job done dihapus setelah selesai selama ini (job tetap
+	// ada dengan status expired). <= 0 => DefaultRetention.
+	Retention time.Duration
+	// HeartbeatInterval: job running diperbarui tiap interval ini; job dengan
+	// heartbeat lebih tua dari 3x interval dikembalikan ke antrian.
+	HeartbeatInterval time.Duration
+}
+
+const (
+	DefaultRetention         = 7 * 24 * time.Hour
+	DefaultHeartbeatInterval = 30 * time.Second
+
+	// janitorInterval: jeda requeue job yatim & hapus file kedaluwarsa.
+	janitorInterval = time.Minute
+	expireBatch     = 100
+)
+
+type service struct {
+	repo     Repository
+	storage  Storage
+	txs      transaction.Service
+	renderer Renderer
+	cfg      Config
+	queue    chan string
+	owner    string // id instance ini, lihat Job.Owner
+}
+
+func NewService(repo Repository, storage Storage, txs transaction.Service, renderer Renderer, cfg Config) Service {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < cfg.Workers {
+		cfg.QueueSize = cfg.Workers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	host, _ := os.Hostname()
+	return &service{
+		owner:    truncateRunes(host+"/"+uuid.NewString(), 64),
+		repo:     repo,
+		storage:  storage,
+		txs:      txs,
+		renderer: renderer,
+		cfg:      cfg,
+		queue:    make(chan string, cfg.QueueSize),
+	}
+}
+
+func (s *service) Enqueue(ctx context.Context, in CreateRequest) (Response, error) {
+	if err := ValidateCreate(in); err != nil {
+		return Response{}, err
+	}
+	if in.Format == "" {
+		in.Format = "csv"
+	}
+	filter, err := json.Marshal(in.Filter)
+	if err != nil {
+		return Response{}, err
+	}
+	opts, err := json.Marshal(in.Options)
+	if err != nil {
+		return Response{}, err
+	}
+	job := &Job{
+		ID:      uuid.NewString(),
+		Status:  StatusQueued,
+		Format:  in.Format,
+		Filter:  filter,
+		Options: opts,
+	}
+	job.FileName = s.renderer.FileName(job)
+	if err := s.repo.Create(ctx, job); err != nil {
+		return Response{}, err
+	}
+	s.dispatch(job.ID)
+	return ToResponse(job), nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (Response, error) {
+	job, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Response{}, err
+	}
+	if job == nil {
+		return Response{}, ErrNotFound
+	}
+	return ToResponse(job), nil
+}
+
+func (s *service) Artifact(ctx context.Context, id string) (string, string, error) {
+	job, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return "", "", err
+	}
+	if job == nil {
+		return "", "", ErrNotFound
+	}
+	if job.Status == StatusExpired {
+		return "", "", ErrExpired
+	}
+	if job.Status != StatusDone {
+		return "", "", ErrNotReady
+	}
+	return job.FilePath, job.FileName, nil
+}
+
+// dispatch mengirim job ke worker tanpa blocking. Jika antrian penuh, job
+// tetap "queued" di database dan akan diambil oleh poller.
+func (s *service) dispatch(id string) {
+	select {
+	case s.queue <- id:
+	default:
+	}
+}
+
+func (s *service) Start(ctx context.Context) {
+	s.requeueStale(ctx)
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.worker(ctx)
+	}
+	go s.poll(ctx)
+	go s.janitor(ctx)
+}
+
+// requeueStale: hanya job yang heartbeat-nya basi, jadi job yang sedang
+// dikerjakan instance lain tetap jalan.
+func (s *service) requeueStale(ctx context.Context) {
+	n, err := s.repo.RequeueStale(ctx, time.Now().Add(-3*s.cfg.HeartbeatInterval))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("export: requeue stale jobs: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Printf("export: requeued %d interrupted job(s)", n)
+	}
+}
+
+// janitor: requeue job yatim & hapus file job yang melewati retensi.
+func (s *service) janitor(ctx context.Context) {
+	t := time.NewTicker(janitorInterval)
+	defer t.Stop()
+	for {
+		s.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.requeueStale(ctx)
+	}
+}
+
+func (s *service) expire(ctx context.Context) {
+	for {
+		jobs, err := s.repo.ListExpired(ctx, time.Now().Add(-s.cfg.Retention), expireBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("export: list expired jobs: %v", err)
+			}
+			return
+		}
+		for _, j := range jobs {
+			if err := s.storage.Remove(j.FilePath); err != nil {
+				log.Printf("export %s: remove artifact: %v", j.ID, err)
+				return // coba lagi di putaran berikutnya
+			}
+			if err := s.repo.MarkExpired(ctx, j.ID); err != nil {
+				log.Printf("export %s: mark expired: %v", j.ID, err)
+				return
+			}
+		}
+		if len(jobs) < expireBatch {
+			return
+		}
+	}
+}
+
+// poll mengisi antrian dari database: job yang belum masuk channel (antrian
+// penuh, atau tersisa dari sebelum restart).
+func (s *service) poll(ctx context.Context) {
+	t := time.NewTicker(s.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		jobs, err := s.repo.ListByStatus(ctx, StatusQueued, s.cfg.QueueSize)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("export: poll queued jobs: %v", err)
+		}
+		for _, j := range jobs {
+			s.dispatch(j.ID)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *service) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			s.run(ctx, id)
+		}
+	}
+}
+
+func (s *service) run(ctx context.Context, id string) {
+	// Claim atomik: id yang sama bisa masuk antrian lebih dari sekali.
+	ok, err := s.repo.Claim(ctx, id, s.owner)
+	if err != nil {
+		log.Printf("export %s: claim: %v", id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	job, err := s.repo.Get(ctx, id)
+	if err != nil || job == nil {
+		log.Printf("export %s: load: %v", id, err)
+		return
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if s.cfg.JobTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		jobCtx, cancelTimeout = context.WithTimeout(jobCtx, s.cfg.JobTimeout)
+		defer cancelTimeout()
+	}
+	stop := s.heartbeat(ctx, id, func() { cancel(ErrLeaseLost) })
+	defer stop()
+
+	err = s.process(jobCtx, job)
+	if errors.Is(context.Cause(jobCtx), ErrLeaseLost) {
+		err = ErrLeaseLost
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrLeaseLost):
+		log.Printf("export %s: lease lost, result discarded", id)
+	default:
+		log.Printf("export %s: failed: %v", id, err)
+		// pakai ctx induk: jobCtx mungkin sudah timeout
+		if err := s.repo.MarkFailed(ctx, id, s.owner, err.Error()); err != nil {
+			log.Printf("export %s: mark failed: %v", id, err)
+		}
+	}
+}
+
+// heartbeat memperbarui Job.HeartbeatAt sampai stop dipanggil; lost
+// dipanggil jika job ternyata sudah bukan milik instance ini.
+func (s *service) heartbeat(ctx context.Context, id string, lost func()) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(s.cfg.HeartbeatInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				err := s.repo.Heartbeat(ctx, id, s.owner)
+				switch {
+				case errors.Is(err, ErrLeaseLost):
+					lost()
+					return
+				case err != nil && ctx.Err() == nil:
+					log.Printf("export %s: heartbeat: %v", id, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *service) process(ctx context.Context, job *Job) error {
+	f, err := job.DecodeFilter()
+	if err != nil {
+		return fmt.Errorf("decode filter: %w", err)
+	}
+	total, err := s.txs.Count(ctx, f)
+	if err != nil {
+		return fmt.Errorf("count rows: %w", err)
+	}
+	if err := s.repo.SetTotal(ctx, job.ID, s.owner, total); err != nil {
+		return err
+	}
+
+	// nama unik per percobaan: worker lama yang kehilangan lease tidak
+	// menimpa file worker yang sekarang memegang job
+	art, err := s.storage.Create(job.ID + "-" + uuid.NewString()[:8] + "." + job.Format)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(art, 64*1024)
+	rows, err := s.renderer.Render(ctx, job, bw, func(n int64) {
+		if err := s.repo.SetProgress(ctx, job.ID, s.owner, n); err != nil {
+			log.Printf("export %s: progress: %v", job.ID, err)
+		}
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		_ = art.Abort()
+		return err
+	}
+	path, size, err := art.Commit()
+	if err != nil {
+		_ = art.Abort()
+		return err
+	}
+	if err := s.repo.MarkDone(ctx, job.ID, s.owner, rows, path, size); err != nil {
+		if errors.Is(err, ErrLeaseLost) {
+			_ = s.storage.Remove(path)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Storage menyimpan file hasil export.
+type Storage interface {
+	// Create membuka file tujuan; file baru terlihat di path final setelah
+	// Commit, sehingga download tidak pernah membaca file setengah jadi.
+	Create(name string) (Artifact, error)
+	Remove(path string) error
+}
+
+type Artifact interface {
+	io.Writer
+	Commit() (path string, size int64, err error)
+	Abort() error
+}
+
+// LocalStorage menyimpan artifact di disk lokal.
+type LocalStorage struct{ dir string }
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+func (s *LocalStorage) Create(name string) (Artifact, error) {
+	final := filepath.Join(s.dir, filepath.Base(name))
+	f, err := os.Create(final + ".part")
+	if err != nil {
+		return nil, err
+	}
+	return &localArtifact{f: f, final: final}, nil
+}
+
+func (s *LocalStorage) Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type localArtifact struct {
+	f     *os.File
+	final string
+	size  int64
+}
+
+func (a *localArtifact) Write(p []byte) (int, error) {
+	n, err := a.f.Write(p)
+	a.size += int64(n)
+	return n, err
+}
+
+func (a *localArtifact) Commit() (string, int64, error) {
+	if err := a.f.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(a.f.Name(), a.final); err != nil {
+		return "", 0, err
+	}
+	return a.final, a.size, nil
+}
+
+func (a *localArtifact) Abort() error {
+	_ = a.f.Close()
+	return os.Remove(a.f.Name())
+}
@@ -0,0 +1,49 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+)
+
+// Record menyimpan satu Idempotency-Key: fingerprint request dan response
+// lengkap (status, content type, header terpilih, body envelope) untuk di-replay.
+type Record struct {
+	Key         string `gorm:"primaryKey;size:255"`
+	Fingerprint string `gorm:"size:64"`
+	Status      string `gorm:"size:16"`
+	StatusCode  int
+	ContentType string         `gorm:"size:128"`
+	Headers     datatypes.JSON // map nama header => nilai, lihat Response.Headers
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Record) TableName() string { return "idempotency_keys" }
+
+// Response: response handler yang disimpan untuk replay.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+}
+
+// Response mengembalikan response tersimpan (record completed).
+func (r *Record) Response() Response {
+	var headers map[string]string
+	_ = json.Unmarshal(r.Headers, &headers) // kosong / null => tanpa header
+	return Response{StatusCode: r.StatusCode, ContentType: r.ContentType, Headers: headers, Body: r.Body}
+}
+
+// Expired: record processing yang lewat batas dianggap ditinggal (proses
+// mati) dan boleh diambil alih.
+func (r *Record) Expired(now time.Time) bool { return !now.Before(r.ExpiresAt) }
@@ -0,0 +1,18 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Acquire menyimpan record baru (INSERT ... ON CONFLICT DO NOTHING);
+	// false jika key sudah ada.
+	Acquire(ctx context.Context, r *Record) (bool, error)
+	Get(ctx context.Context, key string) (*Record, error)
+	Complete(ctx context.Context, key string, res Response, expiresAt time.Time) error
+	Delete(ctx context.Context, key string) error
+	// DeleteIfExpired menghapus key hanya jika sudah kedaluwarsa.
+	DeleteIfExpired(ctx context.Context, key string, now time.Time) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRepository struct{ db *gorm.DB }
+
+func NewGormRepository(db *gorm.DB) Repository { return &gormRepository{db: db} }
+
+func (r *gormRepository) Acquire(ctx context.Context, rec *Record) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(rec)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *gormRepository) Get(ctx context.Context, key string) (*Record, error) {
+	var out Record
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&out).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *gormRepository) Complete(ctx context.Context, key string, res Response, expiresAt time.Time) error {
+	headers, err := json.Marshal(res.Headers)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&Record{}).Where("key = ?", key).Updates(map[string]any{
+		"status":       StatusCompleted,
+		"status_code":  res.StatusCode,
+		"content_type": res.ContentType,
+		"headers":      datatypes.JSON(headers),
+		"body":         res.Body,
+		"expires_at":   expiresAt,
+		"updated_at":   time.Now(),
+	}).Error
+}
+
+func (r *gormRepository) Delete(ctx context.Context, key string) error {
+	return r.db.WithContext(ctx).Where("key = ?", key).Delete(&Record{}).Error
+}
+
+func (r *gormRepository) DeleteIfExpired(ctx context.Context, key string, now time.Time) error {
+	return r.db.WithContext(ctx).Where("key = ? AND expires_at <= ?", key, now).Delete(&Record{}).Error
+}
+
+func (r *gormRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,119 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrMismatch   = errors.New("idempotency_key_reused_with_different_request")
+	ErrInProgress = errors.New("idempotency_key_in_progress")
+)
+
+type Config struct {
+	TTL time.Duration // lama response disimpan untuk replay
+	// LockTimeout: batas minimum record "processing" sebelum boleh diambil
+	// alih. Begin memakai yang lebih panjang antara ini dan lock per request.
+	LockTimeout time.Duration
+}
+
+type Service interface {
+	// Begin mengklaim key untuk request dengan fingerprint ini:
+	//   - (nil, nil): key baru; jalankan handler lalu Complete / Release
+	//   - (rec, nil): request yang sama sudah selesai; replay rec
+	//   - ErrMismatch: key dipakai untuk request lain
+	//   - ErrInProgress: request yang sama sedang diproses (duplikat bersamaan)
+	// lock: lama key dikunci untuk request ini (>= batas waktu handler-nya),
+	// minimal Config.LockTimeout.
+	Begin(ctx context.Context, key, fingerprint string, lock time.Duration) (*Record, error)
+	Complete(ctx context.Context, key string, res Response) error
+	// Release menghapus key (handler gagal), sehingga retry diproses ulang.
+	Release(ctx context.Context, key string) error
+}
+
+type service struct {
+	repo        Repository
+	cfg         Config
+	lastCleanup atomic.Int64 // unix nano
+}
+
+func NewService(repo Repository, cfg Config) Service {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	return &service{repo: repo, cfg: cfg}
+}
+
+func (s *service) Begin(ctx context.Context, key, fingerprint string, lock time.Duration) (*Record, error) {
+	now := time.Now()
+	if lock < s.cfg.LockTimeout {
+		lock = s.cfg.LockTimeout
+	}
+	s.cleanup(now)
+	// percobaan kedua hanya terjadi jika record lama kedaluwarsa / hilang
+	for attempt := 0; attempt < 2; attempt++ {
+		// INSERT ... ON CONFLICT: dari request bersamaan hanya satu yang menang
+		ok, err := s.repo.Acquire(ctx, &Record{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      StatusProcessing,
+			ExpiresAt:   now.Add(lock),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+		rec, err := s.repo.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			continue
+		}
+		if rec.Expired(now) {
+			if err := s.repo.DeleteIfExpired(ctx, key, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if rec.Fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if rec.Status == StatusProcessing {
+			return nil, ErrInProgress
+		}
+		return rec, nil
+	}
+	return nil, ErrInProgress
+}
+
+func (s *service) Complete(ctx context.Context, key string, res Response) error {
+	return s.repo.Complete(ctx, key, res, time.Now().Add(s.cfg.TTL))
+}
+
+func (s *service) Release(ctx context.Context, key string) error {
+	return s.repo.Delete(ctx, key)
+}
+
+// cleanup menghapus key kedaluwarsa di background, paling sering sekali per menit.
+func (s *service) cleanup(now time.Time) {
+	last := s.lastCleanup.Load()
+	if now.UnixNano()-last < int64(time.Minute) || !s.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := s.repo.DeleteExpired(ctx, now); err != nil {
+			log.Printf("idempotency: cleanup expired: %v", err)
+		}
+	}()
+}
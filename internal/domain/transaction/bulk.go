@@ -0,0 +1,82 @@
+package transaction
+
+import "errors"
+
+// Status per item bulk create.
+const (
+	BulkCreated      = "created"
+	BulkError        = "error"         // validasi / gagal simpan
+	BulkDuplicate    = "duplicate"     // transaction_id / no_ref sudah dipakai (di DB atau item sebelumnya)
+	BulkSkipped      = "skipped"       // atomic: item valid, tetapi batch dibatalkan
+	BulkNotAttempted = "not_attempted" // non-atomic: waktu habis sebelum item disimpan
+)
+
+// MaxBulkItems: batas jumlah item per request bulk create.
+const MaxBulkItems = 5000
+
+// bulkChunkSize: item per CreateBatch pada mode non-atomic. Chunk yang gagal
+// diulang per item supaya item lain tetap tersimpan.
+const bulkChunkSize = 500
+
+// BulkItemResult: hasil satu item, Index = posisi di input (0-based).
+type BulkItemResult struct {
+	Index                    int    `json:"index"`
+	Status                   string `json:"status"`
+	TransactionID            string `json:"transaction_id,omitempty"`
+	Error                    string `json:"error,omitempty"`
+	ConflictingTransactionID string `json:"conflicting_transaction_id,omitempty"`
+}
+
+func (r *BulkItemResult) fail(err error) {
+	var dup *DuplicateError
+	r.Status = BulkError
+	if errors.As(err, &dup) {
+		r.Status = BulkDuplicate
+		r.ConflictingTransactionID = dup.ConflictID
+	}
+	r.Error = err.Error()
+}
+
+type BulkSummary struct {
+	Atomic       bool `json:"atomic"`
+	DryRun       bool `json:"dry_run"` // diisi handler; created = akan dibuat
+	Total        int  `json:"total"`
+	Created      int  `json:"created"`
+	Errors       int  `json:"errors"`
+	Duplicates   int  `json:"duplicates"`
+	Skipped      int  `json:"skipped"`
+	NotAttempted int  `json:"not_attempted"` // > 0 => berhenti di tengah, item created tetap tersimpan
+}
+
+type BulkResult struct {
+	Summary BulkSummary      `json:"summary"`
+	Items   []BulkItemResult `json:"items"`
+}
+
+// Incomplete: sebagian item tidak sempat diproses (lihat BulkNotAttempted).
+func (r BulkResult) Incomplete() bool { return r.Summary.NotAttempted > 0 }
+
+// Rejected: mode atomic dan ada item yang gagal => tidak ada yang disimpan.
+func (r BulkResult) Rejected() bool {
+	return r.Summary.Atomic && r.Summary.Errors+r.Summary.Duplicates > 0
+}
+
+func (r *BulkResult) summarize() {
+	r.Summary.Total = len(r.Items)
+	for _, it := range r.Items {
+		switch it.Status {
+		case BulkCreated:
+			r.Summary.Created++
+		case BulkError:
+			r.Summary.Errors++
+		case BulkDuplicate:
+			r.Summary.Duplicates++
+		case BulkSkipped:
+			r.Summary.Skipped++
+		case BulkNotAttempted:
+			r.Summary.NotAttempted++
+		}
+	}
+}
+
+func refKey(orderTypeCode, noRef string) string { return orderTypeCode + "\x00" + noRef }
@@ -0,0 +1,86 @@
+package transaction
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	KindReversal = "REVERSAL"
+	KindRefund   = "REFUND"
+)
+
+// Chain: transaksi asal (root) beserta semua reversal / refund-nya.
+// Ditampilkan di Get, baik untuk root maupun untuk transaksi turunannya.
+type Chain struct {
+	Root     LinkedTransaction   `json:"root"`
+	Children []LinkedTransaction `json:"children"`
+	// ReturnedAmount: total reversal + refund yang tidak FAILED, termasuk
+	// yang sudah di trash (uangnya tetap sudah kembali).
+	ReturnedAmount  decimal.Decimal `json:"returned_amount"`
+	RemainingAmount decimal.Decimal `json:"remaining_amount"`
+}
+
+// LinkedTransaction adalah ringkasan satu transaksi di Chain.
+type LinkedTransaction struct {
+	TransactionID   string          `json:"transaction_id"`
+	Kind            string          `json:"kind,omitempty"`
+	Amount          decimal.Decimal `json:"amount"`
+	Currency        string          `json:"currency"`
+	Status          string          `json:"status"`
+	TransactionDate time.Time       `json:"transaction_date"`
+	DeletedAt       *time.Time      `json:"deleted_at,omitempty"` // turunan di trash
+}
+
+func toLinked(t *Transaction) LinkedTransaction {
+	return LinkedTransaction{
+		TransactionID:   t.TransactionID,
+		Kind:            t.Kind,
+		Amount:          t.Amount,
+		Currency:        t.Currency,
+		Status:          t.Status,
+		TransactionDate: t.TransactionDate,
+		DeletedAt:       deletedAt(t),
+	}
+}
+
+func buildChain(root *Transaction, children []Transaction) *Chain {
+	c := &Chain{Root: toLinked(root), Children: make([]LinkedTransaction, 0, len(children))}
+	for i := range children {
+		c.Children = append(c.Children, toLinked(&children[i]))
+		if children[i].Status != StatusFailed {
+			c.ReturnedAmount = c.ReturnedAmount.Add(children[i].Amount)
+		}
+	}
+	c.RemainingAmount = root.Amount.Sub(c.ReturnedAmount)
+	return c
+}
+
+// newLinked membuat transaksi reversal / refund dari parent: rekening asal &
+// tujuan ditukar, atribut lain disalin.
+func newLinked(parent *Transaction, kind string, amount decimal.Decimal, noRef, reason string, now time.Time) *Transaction {
+	return &Transaction{
+		NoRef:                  noRef,
+		OrderTypeCode:          parent.OrderTypeCode,
+		OrderTypeName:          parent.OrderTypeName,
+		TransactionTypeCode:    parent.TransactionTypeCode,
+		TransactionTypeName:    parent.TransactionTypeName,
+		TransactionDate:        now,
+		FromAccountNumber:      parent.ToAccountNumber,
+		FromAccountName:        parent.ToAccountName,
+		FromAccountProductName: parent.ToAccountProductName,
+		ToAccountNumber:        parent.FromAccountNumber,
+		ToAccountName:          parent.FromAccountName,
+		ToAccountProductName:   parent.FromAccountProductName,
+		Amount:                 amount,
+		Status:                 StatusSuccess,
+		StatusReason:           reason,
+		StatusChangedAt:        &now,
+		ParentTransactionID:    parent.TransactionID,
+		Kind:                   kind,
+		Description:            reason,
+		Method:                 parent.Method,
+		Currency:               parent.Currency,
+	}
+}
@@ -0,0 +1,146 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLinkedRefunds(t *testing.T) {
+	type step struct {
+		refund  string // kosong => reverse
+		wantErr error
+	}
+	tests := []struct {
+		name         string
+		status       string
+		amount       string
+		currency     string
+		steps        []step
+		wantStatus   string
+		wantReturned string
+	}{
+		{"partial refunds", StatusSuccess, "100000", "IDR", []step{{"25000", nil}, {"25000", nil}}, StatusSuccess, "50000"},
+		{"refund exceeds remaining", StatusSuccess, "100000", "IDR", []step{{"60000", nil}, {"50000", ErrRefundExceeded}}, StatusSuccess, "60000"},
+		{"reverse takes remaining", StatusSuccess, "100000", "IDR", []step{{"25000", nil}, {"", nil}}, StatusReversed, "100000"},
+		{"refund after reverse", StatusSuccess, "100", "USD", []step{{"", nil}, {"1", ErrInvalidTransition}}, StatusReversed, "100"},
+		{"reverse twice", StatusSuccess, "100", "USD", []step{{"", nil}, {"", ErrInvalidTransition}}, StatusReversed, "100"},
+		{"reverse after full refund", StatusSuccess, "100", "USD", []step{{"100", nil}, {"", ErrRefundExceeded}}, StatusSuccess, "100"},
+		{"refund pending", StatusPending, "100", "USD", []step{{"1", ErrInvalidTransition}}, StatusPending, ""},
+		{"reverse pending", StatusPending, "100", "USD", []step{{"", ErrInvalidTransition}}, StatusPending, ""},
+		// 0.001 USD ditolak validasi biasa (bukan error domain)
+		{"refund scale", StatusSuccess, "100", "USD", []step{{"0.001", errAny}}, StatusSuccess, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo(testTransaction(tt.status, tt.amount, tt.currency))
+			svc := NewService(repo, Config{})
+			ctx := context.Background()
+			var last Response
+			for i, st := range tt.steps {
+				var err error
+				if st.refund == "" {
+					last, err = svc.Reverse(ctx, testTxID, ReverseRequest{Reason: "test"})
+				} else {
+					last, err = svc.Refund(ctx, testTxID, RefundRequest{Amount: decimal.RequireFromString(st.refund)})
+				}
+				if !matchErr(err, st.wantErr) {
+					t.Fatalf("step %d: err = %v, want %v", i, err, st.wantErr)
+				}
+				if err == nil && last.ParentTransactionID != testTxID {
+					t.Fatalf("step %d: parent = %q", i, last.ParentTransactionID)
+				}
+			}
+			root, err := svc.Get(ctx, testTxID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root.Status != tt.wantStatus {
+				t.Fatalf("root status = %s, want %s", root.Status, tt.wantStatus)
+			}
+			if tt.wantReturned == "" {
+				if root.Chain != nil {
+					t.Fatalf("chain = %+v, want nil", root.Chain)
+				}
+				return
+			}
+			if !root.Chain.ReturnedAmount.Equal(decimal.RequireFromString(tt.wantReturned)) {
+				t.Fatalf("returned = %s, want %s", root.Chain.ReturnedAmount, tt.wantReturned)
+			}
+			if !root.Chain.RemainingAmount.Equal(root.Amount.Sub(root.Chain.ReturnedAmount)) {
+				t.Fatalf("remaining = %s", root.Chain.RemainingAmount)
+			}
+		})
+	}
+}
+
+func TestRefundOfLinkedTransaction(t *testing.T) {
+	svc := NewService(newMemRepo(testTransaction(StatusSuccess, "100000", "IDR")), Config{})
+	ctx := context.Background()
+	child, err := svc.Refund(ctx, testTxID, RefundRequest{Amount: decimal.NewFromInt(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Kind != KindRefund || child.FromAccountNumber != "222" || child.ToAccountNumber != "111" {
+		t.Fatalf("child = %+v", child)
+	}
+	if _, err := svc.Reverse(ctx, child.TransactionID, ReverseRequest{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("reverse refund: err = %v", err)
+	}
+}
+
+// errAny: error apa saja (validasi tanpa sentinel).
+var errAny = errors.New("any error")
+
+func matchErr(err, want error) bool {
+	if want == errAny {
+		return err != nil
+	}
+	return errors.Is(err, want)
+}
+
+func TestLinkedAmountLocked(t *testing.T) {
+	strp := func(s string) *string { return &s }
+	amount := func(s string) *decimal.Decimal {
+		d := decimal.RequireFromString(s)
+		return &d
+	}
+	tests := []struct {
+		name    string
+		refund  bool // parent sudah punya refund
+		child   bool // update dilakukan pada refund-nya
+		in      UpdateRequest
+		wantErr error
+	}{
+		{"unlinked amount", false, false, UpdateRequest{Amount: amount("50000")}, nil},
+		{"parent amount", true, false, UpdateRequest{Amount: amount("50000")}, ErrLinkedAmount},
+		{"parent currency", true, false, UpdateRequest{Currency: strp("USD")}, ErrLinkedAmount},
+		{"parent currency case", true, false, UpdateRequest{Currency: strp("idr")}, nil},
+		{"parent same amount", true, false, UpdateRequest{Amount: amount("100000.00")}, nil},
+		{"parent description", true, false, UpdateRequest{Description: strp("koreksi")}, nil},
+		{"child amount", true, true, UpdateRequest{Amount: amount("1")}, ErrLinkedAmount},
+		{"child description", true, true, UpdateRequest{Description: strp("koreksi")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(newMemRepo(testTransaction(StatusSuccess, "100000", "IDR")), Config{})
+			ctx := context.Background()
+			target := testTxID
+			if tt.refund {
+				child, err := svc.Refund(ctx, testTxID, RefundRequest{Amount: decimal.NewFromInt(60000)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.child {
+					target = child.TransactionID
+				}
+			}
+			if _, err := svc.Update(ctx, target, tt.in, 0); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Cursor menandai posisi terakhir keyset pagination: urutan (created_at, id)
+// DESC. Dikirim ke client sebagai token opaque (base64url JSON) yang terikat
+// pada filter, supaya tidak dipakai ulang dengan filter lain.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+	Filter    string    `json:"f"`
+}
+
+// CursorPage adalah hasil satu halaman keyset pagination.
+type CursorPage struct {
+	Items      []Response
+	NextCursor string // kosong jika tidak ada halaman berikutnya
+	Total      *int64 // hanya diisi jika diminta
+}
+
+func filterHash(f Filter) string {
+	raw, _ := json.Marshal(f)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeCursor(t *Transaction, f Filter) string {
+	raw, _ := json.Marshal(Cursor{CreatedAt: t.CreatedAt, ID: t.ID, Filter: filterHash(f)})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor: token kosong => nil (halaman pertama).
+func decodeCursor(token string, f Filter) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	if c.Filter != filterHash(f) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,93 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	f := Filter{Status: StatusSuccess}
+	tx := &Transaction{ID: 42, CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)}
+	token := encodeCursor(tx, f)
+
+	tests := []struct {
+		name    string
+		token   string
+		filter  Filter
+		wantNil bool
+		wantErr bool
+	}{
+		{"first page", "", f, true, false},
+		{"round trip", token, f, false, false},
+		{"other filter", token, Filter{Status: StatusFailed}, false, true},
+		{"not base64", "%%%", f, false, true},
+		{"not json", "bm90LWpzb24", f, false, true},
+		{"zero id", encodeCursor(&Transaction{CreatedAt: tx.CreatedAt}, f), f, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := decodeCursor(tt.token, tt.filter)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("err = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNil {
+				if c != nil {
+					t.Fatalf("cursor = %+v, want nil", c)
+				}
+				return
+			}
+			if c.ID != tx.ID || !c.CreatedAt.Equal(tx.CreatedAt) {
+				t.Fatalf("cursor = %+v", c)
+			}
+		})
+	}
+}
+
+func TestListCursorPages(t *testing.T) {
+	repo := newMemRepo()
+	for i := 0; i < 5; i++ {
+		tx := testTransaction(StatusSuccess, "1000", "IDR")
+		tx.TransactionID = newTransactionID()
+		tx.CreatedAt = tx.CreatedAt.Add(time.Duration(i) * time.Minute)
+		repo.put(tx)
+	}
+	svc := NewService(repo, Config{})
+	var (
+		seen   []string
+		cursor string
+	)
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("too many pages")
+		}
+		page, err := svc.ListCursor(context.Background(), Filter{}, cursor, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range page.Items {
+			seen = append(seen, it.TransactionID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Fatalf("seen %d rows, want 5", len(seen))
+	}
+	uniq := map[string]bool{}
+	for _, id := range seen {
+		uniq[id] = true
+	}
+	if len(uniq) != 5 {
+		t.Fatalf("duplicate rows across pages: %v", seen)
+	}
+}
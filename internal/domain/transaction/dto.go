@@ -1,78 +1,107 @@
 package transaction
 
 import (
+	"errors"
 	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
 	"gorm.io/datatypes"
 	"time"
 )
 
 type CreateRequest struct {
-	TransactionID          string         `json:"transaction_id"`
-	NoRef                  string         `json:"no_ref"`
-	OrderTypeCode          string         `json:"order_type_code" validate:"required"`
-	OrderTypeName          string         `json:"order_type_name" validate:"required"`
-	TransactionTypeCode    string         `json:"transaction_type_code" validate:"required"`
-	TransactionTypeName    string         `json:"transaction_type_name" validate:"required"`
-	TransactionDate        time.Time      `json:"transaction_date" validate:"required"`
-	FromAccountNumber      string         `json:"from_account_number" validate:"required"`
-	FromAccountName        string         `json:"from_account_name" validate:"required"`
-	FromAccountProductName string         `json:"from_account_product_name" validate:"required"`
-	ToAccountNumber        string         `json:"to_account_number" validate:"required"`
-	ToAccountName          string         `json:"to_account_name" validate:"required"`
-	ToAccountProductName   string         `json:"to_account_product_name" validate:"required"`
-	Amount                 float64        `json:"amount" validate:"required,gt=0"`
-	Status                 string         `json:"status" validate:"required,oneof=PENDING SUCCESS FAILED"`
-	Description            string         `json:"description" validate:"omitempty,max=255"`
-	Method                 string         `json:"method" validate:"required"`
-	Currency               string         `json:"currency" validate:"required,len=3"`
-	Metadata               datatypes.JSON `json:"metadata" validate:"omitempty"`
+	TransactionID          string          `json:"transaction_id"` // kosong => UUIDv7 dari server
+	NoRef                  string          `json:"no_ref" validate:"omitempty,max=64"`
+	OrderTypeCode          string          `json:"order_type_code" validate:"required"`
+	OrderTypeName          string          `json:"order_type_name" validate:"required"`
+	TransactionTypeCode    string          `json:"transaction_type_code" validate:"required"`
+	TransactionTypeName    string          `json:"transaction_type_name" validate:"required"`
+	TransactionDate        time.Time       `json:"transaction_date" validate:"required"`
+	FromAccountNumber      string          `json:"from_account_number" validate:"required"`
+	FromAccountName        string          `json:"from_account_name" validate:"required"`
+	FromAccountProductName string          `json:"from_account_product_name" validate:"required"`
+	ToAccountNumber        string          `json:"to_account_number" validate:"required"`
+	ToAccountName          string          `json:"to_account_name" validate:"required"`
+	ToAccountProductName   string          `json:"to_account_product_name" validate:"required"`
+	Amount                 decimal.Decimal `json:"amount"` // divalidasi di ValidateCreate (skala per currency)
+	Status                 string          `json:"status" validate:"required,oneof=PENDING SUCCESS FAILED"`
+	Description            string          `json:"description" validate:"omitempty,max=255"`
+	Method                 string          `json:"method" validate:"required"`
+	Currency               string          `json:"currency" validate:"required,len=3"`
+	Metadata               datatypes.JSON  `json:"metadata" validate:"omitempty"`
 }
 
 type UpdateRequest struct {
-	NoRef                  *string         `json:"no_ref"`
-	OrderTypeCode          *string         `json:"order_type_code"`
-	OrderTypeName          *string         `json:"order_type_name"`
-	TransactionTypeCode    *string         `json:"transaction_type_code"`
-	TransactionTypeName    *string         `json:"transaction_type_name"`
-	TransactionDate        *time.Time      `json:"transaction_date"`
-	FromAccountNumber      *string         `json:"from_account_number"`
-	FromAccountName        *string         `json:"from_account_name"`
-	FromAccountProductName *string         `json:"from_account_product_name"`
-	ToAccountNumber        *string         `json:"to_account_number"`
-	ToAccountName          *string         `json:"to_account_name"`
-	ToAccountProductName   *string         `json:"to_account_product_name"`
-	Amount                 *float64        `json:"amount"`
-	Status                 *string         `json:"status"`
-	Description            *string         `json:"description"`
-	Method                 *string         `json:"method"`
-	Currency               *string         `json:"currency"`
-	Metadata               *datatypes.JSON `json:"metadata"`
+	NoRef                  *string          `json:"no_ref" validate:"omitempty,max=64"`
+	OrderTypeCode          *string          `json:"order_type_code"`
+	OrderTypeName          *string          `json:"order_type_name"`
+	TransactionTypeCode    *string          `json:"transaction_type_code"`
+	TransactionTypeName    *string          `json:"transaction_type_name"`
+	TransactionDate        *time.Time       `json:"transaction_date"`
+	FromAccountNumber      *string          `json:"from_account_number"`
+	FromAccountName        *string          `json:"from_account_name"`
+	FromAccountProductName *string          `json:"from_account_product_name"`
+	ToAccountNumber        *string          `json:"to_account_number"`
+	ToAccountName          *string          `json:"to_account_name"`
+	ToAccountProductName   *string          `json:"to_account_product_name"`
+	Amount                 *decimal.Decimal `json:"amount"`
+	Status                 *string          `json:"status" validate:"omitempty,oneof=PENDING SUCCESS FAILED"`
+	Description            *string          `json:"description"`
+	Method                 *string          `json:"method"`
+	Currency               *string          `json:"currency"`
+	Metadata               *datatypes.JSON  `json:"metadata"`
+}
+
+// TransitionRequest: body POST /:id/complete & /:id/fail.
+type TransitionRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+// ReverseRequest: body POST /:id/reverse (opsional).
+type ReverseRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+	NoRef  string `json:"no_ref" validate:"max=64"`
+}
+
+// RefundRequest: body POST /:id/refund; amount wajib (refund sebagian).
+type RefundRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason" validate:"max=255"`
+	NoRef  string          `json:"no_ref" validate:"max=64"`
 }
 
 // Response DTO (what we expose)
 
 type Response struct {
-	TransactionID          string         `json:"transaction_id"`
-	NoRef                  string         `json:"no_ref"`
-	OrderTypeCode          string         `json:"order_type_code"`
-	OrderTypeName          string         `json:"order_type_name"`
-	TransactionTypeCode    string         `json:"transaction_type_code"`
-	TransactionTypeName    string         `json:"transaction_type_name"`
-	TransactionDate        time.Time      `json:"transaction_date"`
-	FromAccountNumber      string         `json:"from_account_number"`
-	FromAccountName        string         `json:"from_account_name"`
-	FromAccountProductName string         `json:"from_account_product_name"`
-	ToAccountNumber        string         `json:"to_account_number"`
-	ToAccountName          string         `json:"to_account_name"`
-	ToAccountProductName   string         `json:"to_account_product_name"`
-	Amount                 float64        `json:"amount"`
-	Status                 string         `json:"status"`
-	Description            string         `json:"description"`
-	Method                 string         `json:"method"`
-	Currency               string         `json:"currency"`
-	Metadata               datatypes.JSON `json:"metadata"`
-	CreatedAt              time.Time      `json:"created_at"`
-	UpdatedAt              time.Time      `json:"updated_at"`
+	TransactionID          string          `json:"transaction_id"`
+	NoRef                  string          `json:"no_ref"`
+	OrderTypeCode          string          `json:"order_type_code"`
+	OrderTypeName          string          `json:"order_type_name"`
+	TransactionTypeCode    string          `json:"transaction_type_code"`
+	TransactionTypeName    string          `json:"transaction_type_name"`
+	TransactionDate        time.Time       `json:"transaction_date"`
+	FromAccountNumber      string          `json:"from_account_number"`
+	FromAccountName        string          `json:"from_account_name"`
+	FromAccountProductName string          `json:"from_account_product_name"`
+	ToAccountNumber        string          `json:"to_account_number"`
+	ToAccountName          string          `json:"to_account_name"`
+	ToAccountProductName   string          `json:"to_account_product_name"`
+	Amount                 decimal.Decimal `json:"amount"` // string, mis. "150000"
+	Status                 string          `json:"status"`
+	StatusReason           string          `json:"status_reason,omitempty"`
+	StatusChangedAt        *time.Time      `json:"status_changed_at,omitempty"`
+	ParentTransactionID    string          `json:"parent_transaction_id,omitempty"`
+	Kind                   string          `json:"kind,omitempty"`
+	Description            string          `json:"description"`
+	Method                 string          `json:"method"`
+	Currency               string          `json:"currency"`
+	Metadata               datatypes.JSON  `json:"metadata"`
+	Version                int64           `json:"version"`
+	CreatedAt              time.Time       `json:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at"`
+	DeletedAt              *time.Time      `json:"deleted_at,omitempty"` // hanya di trash
+
+	// Chain hanya diisi oleh Get (lihat Chain).
+	Chain *Chain `json:"chain,omitempty"`
 }
 
 func ToResponse(e *Transaction) Response {
@@ -92,21 +121,73 @@ func ToResponse(e *Transaction) Response {
 		ToAccountProductName:   e.ToAccountProductName,
 		Amount:                 e.Amount,
 		Status:                 e.Status,
+		StatusReason:           e.StatusReason,
+		StatusChangedAt:        e.StatusChangedAt,
+		ParentTransactionID:    e.ParentTransactionID,
+		Kind:                   e.Kind,
 		Description:            e.Description,
 		Method:                 e.Method,
 		Currency:               e.Currency,
 		Metadata:               e.Metadata,
+		Version:                e.Version,
 		CreatedAt:              e.CreatedAt,
 		UpdatedAt:              e.UpdatedAt,
+		DeletedAt:              deletedAt(e),
 	}
 }
 
+func deletedAt(e *Transaction) *time.Time {
+	if !e.DeletedAt.Valid {
+		return nil
+	}
+	t := e.DeletedAt.Time
+	return &t
+}
+
 var validate = validator.New()
 
 func ValidateCreate(r CreateRequest) error {
-	return validate.Struct(r)
+	if err := validate.Struct(r); err != nil {
+		return err
+	}
+	if r.TransactionID != "" {
+		if _, err := normalizeTransactionID(r.TransactionID); err != nil {
+			return err
+		}
+	}
+	return validateAmount(r.Amount, r.Currency)
+}
+
+// asUpdate: semua field r sebagai UpdateRequest (upsert import).
+func (r CreateRequest) asUpdate() UpdateRequest {
+	return UpdateRequest{
+		NoRef:                  &r.NoRef,
+		OrderTypeCode:          &r.OrderTypeCode,
+		OrderTypeName:          &r.OrderTypeName,
+		TransactionTypeCode:    &r.TransactionTypeCode,
+		TransactionTypeName:    &r.TransactionTypeName,
+		TransactionDate:        &r.TransactionDate,
+		FromAccountNumber:      &r.FromAccountNumber,
+		FromAccountName:        &r.FromAccountName,
+		FromAccountProductName: &r.FromAccountProductName,
+		ToAccountNumber:        &r.ToAccountNumber,
+		ToAccountName:          &r.ToAccountName,
+		ToAccountProductName:   &r.ToAccountProductName,
+		Amount:                 &r.Amount,
+		Status:                 &r.Status,
+		Description:            &r.Description,
+		Method:                 &r.Method,
+		Currency:               &r.Currency,
+		Metadata:               &r.Metadata,
+	}
 }
 
 func (u UpdateRequest) Validate() error {
-	return validate.Struct(u) // fields are optional
+	if err := validate.Struct(u); err != nil { // fields are optional
+		return err
+	}
+	if u.Amount != nil && !u.Amount.IsPositive() {
+		return errors.New("amount must be greater than 0")
+	}
+	return nil
 }
@@ -0,0 +1,34 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error domain transaksi; dipetakan ke status HTTP di deliveries/http/error_map.go.
+var (
+	ErrNotFound          = errors.New("not_found")
+	ErrInvalidCursor     = errors.New("invalid_cursor")
+	ErrInvalidTransition = errors.New("invalid_status_transition")
+	ErrRefundExceeded    = errors.New("refund_exceeds_original")
+	ErrDuplicate         = errors.New("duplicate")
+	ErrVersionConflict   = errors.New("version_conflict")
+	ErrNotDeleted        = errors.New("not_deleted")                  // restore / purge transaksi yang tidak di trash
+	ErrRetention         = errors.New("retention_period_not_elapsed") // purge sebelum masa retensi
+	ErrHasChildren       = errors.New("has_linked_transactions")      // purge parent reversal / refund
+	ErrReadOnlyField     = errors.New("read_only_field")              // upsert mengubah parent_transaction_id / kind
+	ErrLinkedAmount      = errors.New("linked_amount_locked")         // ubah amount / currency reversal, refund atau parent-nya
+)
+
+// DuplicateError: transaction_id atau (order_type_code, no_ref) sudah dipakai
+// transaksi lain. errors.Is(err, ErrDuplicate) == true.
+type DuplicateError struct {
+	Field      string // "transaction_id" | "no_ref"
+	ConflictID string // transaction_id yang sudah ada
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate_%s: already used by transaction %s", e.Field, e.ConflictID)
+}
+
+func (e *DuplicateError) Is(target error) bool { return target == ErrDuplicate }
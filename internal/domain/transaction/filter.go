@@ -0,0 +1,101 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Filter mempersempit query list & export langsung di SQL (bukan di Go).
+// Field kosong / zero / nil berarti tidak difilter.
+type Filter struct {
+	From                time.Time        `json:"from,omitzero"`
+	To                  time.Time        `json:"to,omitzero"`
+	Status              string           `json:"status,omitempty"`
+	Currency            string           `json:"currency,omitempty"`
+	Method              string           `json:"method,omitempty"`
+	OrderTypeCode       string           `json:"order_type_code,omitempty"`
+	TransactionTypeCode string           `json:"transaction_type_code,omitempty"`
+	FromAccountNumber   string           `json:"from_account_number,omitempty"`
+	ToAccountNumber     string           `json:"to_account_number,omitempty"`
+	MinAmount           *decimal.Decimal `json:"min_amount,omitempty"`
+	MaxAmount           *decimal.Decimal `json:"max_amount,omitempty"`
+
+	// Meta: kecocokan di dalam kolom metadata, key = path bertitik
+	// ("merchant.id"), dari query ?meta.merchant.id=123.
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// Query: full-text search (prefix) atas no_ref, nama pihak & description.
+	Query string `json:"q,omitempty"`
+
+	// Sort: daftar kolom dipisah koma, prefix "-" untuk DESC,
+	// mis. "-amount,transaction_date". Kosong => urutan default endpoint.
+	Sort string `json:"sort,omitempty"`
+}
+
+// sortColumns: kolom yang boleh dipakai di Sort (whitelist, aman untuk SQL).
+var sortColumns = map[string]string{
+	"transaction_date":      "transaction_date",
+	"created_at":            "created_at",
+	"updated_at":            "updated_at",
+	"amount":                "amount",
+	"status":                "status",
+	"currency":              "currency",
+	"method":                "method",
+	"order_type_code":       "order_type_code",
+	"transaction_type_code": "transaction_type_code",
+	"from_account_number":   "from_account_number",
+	"to_account_number":     "to_account_number",
+	"transaction_id":        "transaction_id",
+}
+
+func (f Filter) Validate() error {
+	if !f.From.IsZero() && !f.To.IsZero() && f.From.After(f.To) {
+		return errors.New("from must not be after to")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && f.MinAmount.GreaterThan(*f.MaxAmount) {
+		return errors.New("min_amount must not be greater than max_amount")
+	}
+	if err := validateMeta(f.Meta); err != nil {
+		return err
+	}
+	if f.Query != "" {
+		if _, err := searchQuery(f.Query); err != nil {
+			return err
+		}
+	}
+	_, err := f.orderBy()
+	return err
+}
+
+// orderBy menerjemahkan Sort ke klausa ORDER BY (tanpa tie-breaker id).
+// Hasil kosong jika Sort kosong.
+func (f Filter) orderBy() (string, error) {
+	if strings.TrimSpace(f.Sort) == "" {
+		return "", nil
+	}
+	var (
+		parts []string
+		seen  = map[string]bool{}
+	)
+	for _, item := range strings.Split(f.Sort, ",") {
+		item = strings.TrimSpace(item)
+		dir := "ASC"
+		if strings.HasPrefix(item, "-") {
+			item, dir = item[1:], "DESC"
+		}
+		col, ok := sortColumns[item]
+		if !ok {
+			return "", fmt.Errorf("invalid sort column %q", item)
+		}
+		if seen[col] {
+			return "", fmt.Errorf("duplicate sort column %q", item)
+		}
+		seen[col] = true
+		parts = append(parts, col+" "+dir)
+	}
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,138 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// systemActor: actor untuk perubahan tanpa audit.Info (mis. dari worker).
+const systemActor = "system"
+
+const (
+	ActionCreate  = "CREATE"
+	ActionUpdate  = "UPDATE"
+	ActionStatus  = "STATUS"
+	ActionDelete  = "DELETE"  // soft delete (masuk trash)
+	ActionRestore = "RESTORE" // keluar dari trash
+	ActionPurge   = "PURGE"   // hard delete; history tetap disimpan
+)
+
+// History adalah satu entri audit trail (append-only; UPDATE/DELETE ditolak
+// trigger di DB). Ditulis di transaksi DB yang sama dengan perubahannya.
+type History struct {
+	ID            uint           `gorm:"primaryKey"`
+	TransactionID string         `gorm:"size:36;index:idx_transaction_history_tx,priority:1"`
+	Action        string         `gorm:"size:16"`
+	Changes       datatypes.JSON // map field => FieldChange
+	Actor         string         `gorm:"size:128"`
+	RequestID     string         `gorm:"size:64"`
+	Version       int64          // version transaksi setelah perubahan
+	CreatedAt     time.Time      `gorm:"index:idx_transaction_history_tx,priority:2"`
+}
+
+func (History) TableName() string { return "transaction_history" }
+
+// FieldChange: nilai JSON sebelum & sesudah (null = tidak ada).
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// historyIgnored: berubah di setiap write, tidak informatif di diff.
+var historyIgnored = map[string]bool{"updated_at": true, "version": true}
+
+// diffTransactions membandingkan field JSON before & after (nil = baris
+// belum ada / sudah dihapus). Hanya field yang berbeda yang dikembalikan.
+func diffTransactions(before, after *Transaction) map[string]FieldChange {
+	b, a := fieldValues(before), fieldValues(after)
+	out := map[string]FieldChange{}
+	for _, k := range unionKeys(a, b) {
+		from, to := nullIfEmpty(b[k]), nullIfEmpty(a[k])
+		if historyIgnored[k] || bytes.Equal(from, to) {
+			continue
+		}
+		out[k] = FieldChange{From: from, To: to}
+	}
+	return out
+}
+
+// fieldValues: nilai JSON kanonik per field (key object diurutkan, spasi
+// dibuang) supaya metadata dari jsonb tidak terlihat berubah.
+func fieldValues(t *Transaction) map[string]json.RawMessage {
+	out := map[string]json.RawMessage{}
+	if t == nil {
+		return out
+	}
+	raw, _ := json.Marshal(t)
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(raw, &fields)
+	for k, v := range fields {
+		var x any
+		if json.Unmarshal(v, &x) == nil {
+			v, _ = json.Marshal(x)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func unionKeys(a, b map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func nullIfEmpty(v json.RawMessage) json.RawMessage {
+	if len(v) == 0 {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+// HistoryResponse DTO.
+type HistoryResponse struct {
+	ID            uint                   `json:"id"`
+	TransactionID string                 `json:"transaction_id"`
+	Action        string                 `json:"action"`
+	Changes       map[string]FieldChange `json:"changes"`
+	Actor         string                 `json:"actor"`
+	RequestID     string                 `json:"request_id,omitempty"`
+	Version       int64                  `json:"version"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+func ToHistoryResponse(h *History) HistoryResponse {
+	res := HistoryResponse{
+		ID:            h.ID,
+		TransactionID: h.TransactionID,
+		Action:        h.Action,
+		Actor:         h.Actor,
+		RequestID:     h.RequestID,
+		Version:       h.Version,
+		CreatedAt:     h.CreatedAt,
+	}
+	_ = json.Unmarshal(h.Changes, &res.Changes)
+	return res
+}
+
+// SortedFields: nama field di Changes, urut alfabet (untuk CSV).
+func (h HistoryResponse) SortedFields() []string {
+	keys := make([]string, 0, len(h.Changes))
+	for k := range h.Changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,27 @@
+package transaction
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// newTransactionID: UUIDv7 (urut waktu) supaya insert berurutan di index
+// transaction_id.
+func newTransactionID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// normalizeTransactionID menerima UUID kanonik (versi apa pun,
+// 8-4-4-4-12) dan mengembalikannya dalam huruf kecil.
+func normalizeTransactionID(id string) (string, error) {
+	u, err := uuid.Parse(id)
+	if err != nil || len(id) != 36 {
+		return "", errors.New("transaction_id must be a UUID (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx)")
+	}
+	return u.String(), nil
+}
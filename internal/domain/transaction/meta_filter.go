@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxMetaFilters = 10
+	maxMetaDepth   = 5
+)
+
+var metaKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// jsonNumberPattern: grammar angka JSON (RFC 8259).
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// validateMeta memeriksa path Filter.Meta ("merchant.id" => metadata.merchant.id).
+func validateMeta(meta map[string]string) error {
+	if len(meta) > maxMetaFilters {
+		return fmt.Errorf("too many meta filters (max %d)", maxMetaFilters)
+	}
+	for path := range meta {
+		keys := strings.Split(path, ".")
+		if len(keys) > maxMetaDepth {
+			return fmt.Errorf("meta.%s: path too deep (max %d)", path, maxMetaDepth)
+		}
+		for _, k := range keys {
+			if !metaKeyPattern.MatchString(k) {
+				return fmt.Errorf("meta.%s: invalid key %q", path, k)
+			}
+		}
+	}
+	return nil
+}
+
+// metaDocs membangun dokumen JSONB untuk operator containment (@>), mis.
+// ("merchant.id", "123") => {"merchant":{"id":123}} dan {"merchant":{"id":"123"}}.
+// Nilai yang terlihat seperti angka / boolean dicocokkan dengan tipe aslinya
+// maupun sebagai string. Angka dikirim apa adanya (json.Number, tanpa float64)
+// supaya ID di atas 2^53 tidak berubah; jsonb membandingkannya sebagai numeric.
+// Dokumen dikirim sebagai parameter, bukan disisipkan.
+func metaDocs(path, value string) []string {
+	candidates := []any{value}
+	switch {
+	case value == "true" || value == "false":
+		candidates = append(candidates, value == "true")
+	case value == "null":
+		candidates = append(candidates, nil)
+	default:
+		if jsonNumberPattern.MatchString(value) {
+			candidates = append(candidates, json.Number(value))
+		}
+	}
+
+	keys := strings.Split(path, ".")
+	docs := make([]string, 0, len(candidates))
+	for _, v := range candidates {
+		doc := v
+		for i := len(keys) - 1; i >= 0; i-- {
+			doc = map[string]any{keys[i]: doc}
+		}
+		raw, _ := json.Marshal(doc)
+		docs = append(docs, string(raw))
+	}
+	return docs
+}
+
+// sortedMetaPaths: urutan deterministik untuk SQL yang stabil.
+func sortedMetaPaths(meta map[string]string) []string {
+	paths := make([]string, 0, len(meta))
+	for p := range meta {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,81 @@
+package transaction
+
+import "gorm.io/gorm"
+
+// preMigrations dijalankan sebelum AutoMigrate (mengubah data yang sudah ada).
+var preMigrations = []string{
+	// amount float8 => NUMERIC(20,4); dibulatkan 4 desimal agar noise float
+	// (mis. 0.30000000000000004) hilang. Hanya jalan sekali (cek tipe kolom).
+	`DO $$
+	BEGIN
+		IF EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'transactions'
+				AND column_name = 'amount' AND data_type IN ('double precision', 'real')
+		) THEN
+			ALTER TABLE transactions ALTER COLUMN amount TYPE numeric(20,4) USING round(amount::numeric, 4);
+		END IF;
+	END $$`,
+}
+
+// migrations: DDL yang tidak bisa diekspresikan lewat tag GORM. Semua
+// statement idempotent, dijalankan berurutan setiap start setelah AutoMigrate.
+var migrations = []string{
+	// full-text search: no_ref (A), nama pihak (B), description (C).
+	// Config 'simple' (tanpa stemming) cocok untuk nama & teks campuran.
+	`ALTER TABLE transactions ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(no_ref, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(from_account_name, '') || ' ' || coalesce(to_account_name, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+		) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_search ON transactions USING GIN (search_vector)`,
+	// no_ref unik per order_type_code (hanya bila diisi). Dilewati dengan
+	// WARNING jika data lama sudah berisi duplikat; service tetap menolak
+	// duplikat baru (FindConflict).
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM transactions WHERE no_ref <> ''
+			GROUP BY order_type_code, no_ref HAVING count(*) > 1
+		) THEN
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_order_no_ref
+				ON transactions (order_type_code, no_ref) WHERE no_ref <> '';
+		ELSE
+			RAISE WARNING 'idx_transactions_order_no_ref not created: duplicate (order_type_code, no_ref) rows exist';
+		END IF;
+	END $$`,
+	// transaction_history append-only: UPDATE / DELETE ditolak di level DB
+	`CREATE OR REPLACE FUNCTION transaction_history_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'transaction_history is append-only';
+	END $$ LANGUAGE plpgsql`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_transaction_history_append_only') THEN
+			CREATE TRIGGER trg_transaction_history_append_only
+				BEFORE UPDATE OR DELETE ON transaction_history
+				FOR EACH ROW EXECUTE FUNCTION transaction_history_append_only();
+		END IF;
+	END $$`,
+	// filter ?meta.x=... (operator @>)
+	`CREATE INDEX IF NOT EXISTS idx_transactions_metadata ON transactions USING GIN (metadata jsonb_path_ops)`,
+}
+
+// Migrate membuat / memperbarui tabel domain transaksi (idempotent).
+func Migrate(db *gorm.DB) error {
+	for _, stmt := range preMigrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.AutoMigrate(&Transaction{}, &History{}, &Snapshot{}, &SnapshotRow{}); err != nil {
+		return err
+	}
+	for _, stmt := range migrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// AmountScale adalah jumlah digit desimal kolom amount di database
+// (NUMERIC(20,4)); cukup untuk semua mata uang di currencyScales.
+const AmountScale = 4
+
+// maxAmount: batas NUMERIC(20,4) (16 digit sebelum koma).
+var maxAmount = decimal.New(1, 16)
+
+// currencyScales: digit desimal yang sah per mata uang (ISO 4217 minor unit).
+// Mata uang yang tidak terdaftar memakai defaultCurrencyScale.
+var currencyScales = map[string]int32{
+	"IDR": 0, "JPY": 0, "KRW": 0, "VND": 0,
+	"USD": 2, "EUR": 2, "SGD": 2, "MYR": 2, "GBP": 2, "AUD": 2, "CNY": 2,
+	"BHD": 3, "KWD": 3, "OMR": 3,
+}
+
+const defaultCurrencyScale int32 = 2
+
+// CurrencyScale mengembalikan jumlah digit desimal untuk mata uang cur.
+func CurrencyScale(cur string) int32 {
+	if s, ok := currencyScales[strings.ToUpper(cur)]; ok {
+		return s
+	}
+	return defaultCurrencyScale
+}
+
+// FormatAmount menulis amount dengan jumlah desimal sesuai mata uang,
+// mis. IDR "150000", USD "10.50".
+func FormatAmount(amount decimal.Decimal, cur string) string {
+	return amount.StringFixed(CurrencyScale(cur))
+}
+
+// validateAmount: amount harus positif dan tidak melebihi skala mata uang.
+func validateAmount(amount decimal.Decimal, cur string) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount must be greater than 0")
+	}
+	if amount.GreaterThanOrEqual(maxAmount) {
+		return fmt.Errorf("amount is too large")
+	}
+	scale := CurrencyScale(cur)
+	if !amount.Equal(amount.Truncate(scale)) {
+		return fmt.Errorf("amount has too many decimals for %s (max %d)", strings.ToUpper(cur), scale)
+	}
+	return nil
+}
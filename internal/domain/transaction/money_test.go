@@ -0,0 +1,60 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		ok       bool
+	}{
+		{"150000", "IDR", true},
+		{"150000.5", "IDR", false},
+		{"10.50", "USD", true},
+		{"10.505", "USD", false},
+		{"1.234", "KWD", true},
+		{"1.2345", "KWD", false},
+		{"1000", "JPY", true},
+		{"10.5", "usd", true},
+		{"10.12", "XYZ", true}, // default 2 desimal
+		{"10.123", "XYZ", false},
+		{"0", "IDR", false},
+		{"-1", "IDR", false},
+		{"9999999999999999", "IDR", true},
+		{"10000000000000000", "IDR", false},
+	}
+	for _, tt := range tests {
+		err := validateAmount(decimal.RequireFromString(tt.amount), tt.currency)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateAmount(%s, %s) = %v, want ok=%v", tt.amount, tt.currency, err, tt.ok)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"150000", "IDR", "150000"},
+		{"150000.0000", "IDR", "150000"},
+		{"10.5", "USD", "10.50"},
+		{"10", "usd", "10.00"},
+		{"1.2", "KWD", "1.200"},
+		{"0.1", "XYZ", "0.10"},
+		// 0.1 + 0.2 persis, tidak 0.30000000000000004
+		{decimal.RequireFromString("0.1").Add(decimal.RequireFromString("0.2")).String(), "USD", "0.30"},
+		// di atas 2^53 tetap persis
+		{"9007199254740993", "IDR", "9007199254740993"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(decimal.RequireFromString(tt.amount), tt.currency); got != tt.want {
+			t.Errorf("FormatAmount(%s, %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -3,15 +3,89 @@ package transaction
 import (
 	"context"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type Repository interface {
 	Create(ctx context.Context, t *Transaction) error
 	GetByTxID(ctx context.Context, txID string) (*Transaction, error)
-	List(ctx context.Context, page, size int) ([]Transaction, int64, error)
+	// Exists: transaction_id ada, termasuk yang di trash.
+	Exists(ctx context.Context, txID string) (bool, error)
+	List(ctx context.Context, f Filter, page, size int) ([]Transaction, int64, error)
+	// ListAfter: keyset pagination urut (created_at, id) DESC, mulai setelah
+	// after (nil = dari awal). Tidak menghitung total.
+	ListAfter(ctx context.Context, f Filter, after *Cursor, limit int) ([]Transaction, error)
+	// Search: full-text search f.Query (wajib) diurutkan relevansi, dengan highlight.
+	Search(ctx context.Context, f Filter, page, size int) ([]SearchHit, int64, error)
+	// Update menulis t hanya jika version di DB masih sama dengan
+	// t.Version (UPDATE ... WHERE version = ?), lalu menaikkan t.Version.
+	// Version sudah berubah => ErrVersionConflict. Amount / currency
+	// reversal, refund atau parent-nya tidak bisa diubah => ErrLinkedAmount
+	// (dicek di bawah lock baris yang sama dengan CreateLinked).
 	Update(ctx context.Context, t *Transaction) error
+	// FindConflict mencari transaksi lain (id berbeda) dengan transaction_id
+	// sama, atau order_type_code + no_ref sama bila no_ref diisi. nil = aman.
+	FindConflict(ctx context.Context, t *Transaction) (*DuplicateError, error)
+	// CreateBatch menyimpan ts (INSERT multi-values per batch) beserta history
+	// CREATE-nya dalam satu transaksi DB: semua tersimpan atau tidak sama sekali.
+	// Unique violation (mis. balapan dengan request lain) => ErrDuplicate.
+	CreateBatch(ctx context.Context, ts []*Transaction) error
+	// FindConflicts: FindConflict untuk banyak transaksi baru sekaligus.
+	// Hasil sejajar dengan ts; nil = aman.
+	FindConflicts(ctx context.Context, ts []*Transaction) ([]*DuplicateError, error)
+	// UpdateStatus mengubah status hanya jika status saat ini masih from
+	// (compare-and-set). false => status sudah berubah oleh request lain.
+	UpdateStatus(ctx context.Context, txID, from, to, reason string, at time.Time) (bool, error)
+	// CreateLinked membuat reversal / refund dalam satu transaksi DB: parent
+	// dikunci (SELECT ... FOR UPDATE), total turunan yang tidak FAILED
+	// (termasuk yang di trash) dihitung, lalu build menghasilkan transaksi
+	// baru. Perubahan status parent oleh build ikut disimpan. Parent tidak
+	// ada => ErrNotFound.
+	CreateLinked(ctx context.Context, parentTxID string, build func(parent *Transaction, returned decimal.Decimal) (*Transaction, error)) (*Transaction, error)
+	// Children: reversal / refund dari parentTxID termasuk yang di trash,
+	// urut created_at.
+	Children(ctx context.Context, parentTxID string) ([]Transaction, error)
+
+	// ListTrash: transaksi yang di-soft-delete, urut f.Sort atau deleted_at DESC.
+	ListTrash(ctx context.Context, f Filter, page, size int) ([]Transaction, int64, error)
+	// Restore mengeluarkan transaksi dari trash. Tidak ada => ErrNotFound,
+	// tidak di trash => ErrNotDeleted.
+	Restore(ctx context.Context, txID string, at time.Time) (*Transaction, error)
+	// Purge menghapus permanen transaksi di trash yang deleted_at-nya sebelum
+	// deletedBefore (ErrRetention), dan bukan parent transaksi lain (ErrHasChildren).
+	Purge(ctx context.Context, txID string, deletedBefore time.Time) error
+
+	// DryRun menjalankan fn dengan Repository di atas satu transaksi DB yang
+	// selalu di-rollback. Write di dalamnya menjadi savepoint, jadi satu write
+	// gagal tidak membatalkan write lain.
+	DryRun(ctx context.Context, fn func(Repository) error) error
+
+	// Semua write di atas (dan DeleteByTxID) menulis transaction_history di
+	// transaksi DB yang sama; actor & request ID dari audit.FromContext.
+	ListHistory(ctx context.Context, txID string, page, size int) ([]History, int64, error)
+	StreamHistory(ctx context.Context, txID string, fn func(*History) error) error
 	DeleteByTxID(ctx context.Context, txID string) error // soft delete
 
 	// Export helpers
-	ListByDateRange(ctx context.Context, from, to time.Time) ([]Transaction, error)
+	// Stream membaca baris yang cocok dengan filter satu per satu lewat cursor
+	// database (urut transaction_date) dan memanggil fn untuk tiap baris,
+	// sehingga memori tetap konstan. limit <= 0 berarti tanpa batas.
+	Stream(ctx context.Context, f Filter, offset, limit int, fn func(*Transaction) error) error
+	Count(ctx context.Context, f Filter) (int64, error)
+
+	// Snapshot export: CreateSnapshot menyalin urutan baris yang cocok dengan
+	// filter (dikelompokkan menurut s.GroupBy) ke export_snapshot_rows dan
+	// mengisi s.RowCount.
+	CreateSnapshot(ctx context.Context, s *Snapshot, f Filter) error
+	GetSnapshot(ctx context.Context, id string) (*Snapshot, error)
+	// CountGroups: jumlah key grup berbeda (lihat GroupBy*) dari baris yang
+	// cocok dengan filter, tanpa membuat snapshot.
+	CountGroups(ctx context.Context, f Filter, groupBy string) (int64, error)
+	// SnapshotGroups menghitung satu part per key grup snapshot.
+	SnapshotGroups(ctx context.Context, s *Snapshot) ([]SnapshotPart, error)
+	SetSnapshotParts(ctx context.Context, id string, parts []SnapshotPart) error
+	// StreamSnapshot membaca baris snapshot dengan seq di (start, end] sesuai urutan.
+	StreamSnapshot(ctx context.Context, id string, start, end int64, fn func(*Transaction) error) error
+	DeleteExpiredSnapshots(ctx context.Context, now time.Time) error
 }
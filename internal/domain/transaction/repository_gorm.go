@@ -2,18 +2,63 @@ package transaction
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/audit"
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type gormRepository struct{ db *gorm.DB }
 
 func NewGormRepository(db *gorm.DB) Repository { return &gormRepository{db: db} }
 
+// errDryRun memaksa rollback transaksi DryRun.
+var errDryRun = errors.New("dry run rollback")
+
+func (r *gormRepository) DryRun(ctx context.Context, fn func(Repository) error) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := fn(&gormRepository{db: tx}); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
 func (r *gormRepository) Create(ctx context.Context, t *Transaction) error {
-	return r.db.WithContext(ctx).Create(t).Error
+	if t.Version == 0 {
+		t.Version = 1
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return writeHistory(ctx, tx, ActionCreate, nil, t)
+	})
+	return r.duplicate(ctx, t, err)
+}
+
+// duplicate menerjemahkan unique violation (butuh gorm.Config.TranslateError)
+// menjadi *DuplicateError berisi transaction_id yang bentrok.
+func (r *gormRepository) duplicate(ctx context.Context, t *Transaction, err error) error {
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return err
+	}
+	if dup, _ := r.FindConflict(ctx, t); dup != nil {
+		return dup
+	}
+	return err
 }
 
 func (r *gormRepository) GetByTxID(ctx context.Context, txID string) (*Transaction, error) {
@@ -25,40 +70,722 @@ func (r *gormRepository) GetByTxID(ctx context.Context, txID string) (*Transacti
 	return &out, err
 }
 
-func (r *gormRepository) List(ctx context.Context, page, size int) ([]Transaction, int64, error) {
+func (r *gormRepository) Exists(ctx context.Context, txID string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&Transaction{}).
+		Where("transaction_id = ?", txID).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *gormRepository) List(ctx context.Context, f Filter, page, size int) ([]Transaction, int64, error) {
 	var (
 		items []Transaction
 		total int64
 	)
-	db := r.db.WithContext(ctx).Model(&Transaction{})
+	order, err := orderFor(f, "created_at DESC, id DESC")
+	if err != nil {
+		return nil, 0, err
+	}
+	db := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f)
 	if err := db.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
-	if err := db.Order("created_at DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+	if err := db.Order(order).Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
 		return nil, 0, err
 	}
 	return items, total, nil
 }
 
+func (r *gormRepository) ListAfter(ctx context.Context, f Filter, after *Cursor, limit int) ([]Transaction, error) {
+	var items []Transaction
+	db := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f)
+	if after != nil {
+		// row comparison memakai index (created_at, id)
+		db = db.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+	err := db.Order("created_at DESC, id DESC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (r *gormRepository) Search(ctx context.Context, f Filter, page, size int) ([]SearchHit, int64, error) {
+	tsq, err := searchQuery(f.Query)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	// ranking & paging dulu, ts_headline (mahal) hanya untuk baris di halaman ini
+	ranked := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f).
+		Select("transactions.*, ts_rank_cd(search_vector, to_tsquery('simple', ?)) AS rank", tsq).
+		Order("rank DESC, id DESC").
+		Offset((page - 1) * size).
+		Limit(size)
+	hl := func(col string) string {
+		return "ts_headline('simple', coalesce(t." + col + ", ''), to_tsquery('simple', @q), @opts) AS hl_" + col
+	}
+	var hits []SearchHit
+	err = r.db.WithContext(ctx).Table("(?) AS t", ranked).
+		Select("t.*, "+strings.Join([]string{hl("no_ref"), hl("from_account_name"), hl("to_account_name"), hl("description")}, ", "),
+			sql.Named("q", tsq), sql.Named("opts", headlineOpts)).
+		Order("t.rank DESC, t.id DESC").
+		Scan(&hits).Error
+	return hits, total, err
+}
+
 func (r *gormRepository) Update(ctx context.Context, t *Transaction) error {
-	return r.db.WithContext(ctx).Where("transaction_id = ?", t.TransactionID).Updates(t).Error
+	version := t.Version
+	t.Version = version + 1
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockByTxID(tx, t.TransactionID)
+		if err != nil {
+			return err
+		}
+		if !t.Amount.Equal(before.Amount) || !strings.EqualFold(t.Currency, before.Currency) {
+			if err := checkUnlinked(tx, before); err != nil {
+				return err
+			}
+		}
+		// Select("*"): struct Updates melewati zero value, jadi field yang
+		// dikosongkan (description, metadata, status_reason) tidak tersimpan
+		res := tx.Model(t).Select("*").Omit(immutableColumns...).
+			Where("transaction_id = ? AND version = ?", t.TransactionID, version).Updates(t)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		var after Transaction
+		if err := tx.First(&after, before.ID).Error; err != nil {
+			return err
+		}
+		*t = after
+		return writeHistory(ctx, tx, ActionUpdate, before, &after)
+	})
+	if err != nil {
+		t.Version = version
+		return r.duplicate(ctx, t, err)
+	}
+	return nil
 }
 
-func (r *gormRepository) DeleteByTxID(ctx context.Context, txID string) error {
-	return r.db.WithContext(ctx).Where("transaction_id = ?", txID).Delete(&Transaction{}).Error
+// immutableColumns tidak ikut ditulis Update.
+var immutableColumns = []string{"id", "transaction_id", "parent_transaction_id", "kind", "created_at", "deleted_at"}
+
+// lockByTxID: SELECT ... FOR UPDATE; baris tidak ada => ErrNotFound.
+func lockByTxID(tx *gorm.DB, txID string) (*Transaction, error) {
+	var out Transaction
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id = ?", txID).First(&out).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
-func (r *gormRepository) ListByDateRange(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+// writeHistory menambah entri transaction_history di tx, yaitu transaksi DB
+// yang sama dengan perubahannya. before / after nil untuk create / delete.
+func writeHistory(ctx context.Context, tx *gorm.DB, action string, before, after *Transaction) error {
+	h, err := newHistory(ctx, action, before, after)
+	if err != nil {
+		return err
+	}
+	return tx.Create(h).Error
+}
+
+func newHistory(ctx context.Context, action string, before, after *Transaction) (*History, error) {
+	cur := after
+	if cur == nil {
+		cur = before
+	}
+	changes, err := json.Marshal(diffTransactions(before, after))
+	if err != nil {
+		return nil, err
+	}
+	info := audit.FromContext(ctx)
+	if info.Actor == "" {
+		info.Actor = systemActor
+	}
+	return &History{
+		TransactionID: cur.TransactionID,
+		Action:        action,
+		Changes:       changes,
+		Actor:         info.Actor,
+		RequestID:     info.RequestID,
+		Version:       cur.Version,
+	}, nil
+}
+
+func (r *gormRepository) FindConflict(ctx context.Context, t *Transaction) (*DuplicateError, error) {
+	var other Transaction
+	// Unscoped: unique index juga mencakup baris di trash
+	db := r.db.WithContext(ctx).Unscoped().Select("transaction_id", "no_ref", "order_type_code")
+	if t.ID != 0 {
+		db = db.Where("id <> ?", t.ID)
+	}
+	cond := r.db.Where("transaction_id = ?", t.TransactionID)
+	if t.NoRef != "" {
+		cond = cond.Or("order_type_code = ? AND no_ref = ?", t.OrderTypeCode, t.NoRef)
+	}
+	err := db.Where(cond).Take(&other).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	field := "no_ref"
+	if other.TransactionID == t.TransactionID {
+		field = "transaction_id"
+	}
+	return &DuplicateError{Field: field, ConflictID: other.TransactionID}, nil
+}
+
+// createBatchSize: baris per INSERT multi-values di CreateBatch (jauh di
+// bawah batas 65535 parameter Postgres).
+const createBatchSize = 500
+
+func (r *gormRepository) CreateBatch(ctx context.Context, ts []*Transaction) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	for _, t := range ts {
+		if t.Version == 0 {
+			t.Version = 1
+		}
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(ts, createBatchSize).Error; err != nil {
+			return err
+		}
+		hist := make([]*History, 0, len(ts))
+		for _, t := range ts {
+			h, err := newHistory(ctx, ActionCreate, nil, t)
+			if err != nil {
+				return err
+			}
+			hist = append(hist, h)
+		}
+		return tx.CreateInBatches(hist, createBatchSize).Error
+	})
+	if err != nil {
+		// rollback: id dari RETURNING tidak berlaku lagi
+		for _, t := range ts {
+			t.ID = 0
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+	}
+	return err
+}
+
+func (r *gormRepository) FindConflicts(ctx context.Context, ts []*Transaction) ([]*DuplicateError, error) {
+	out := make([]*DuplicateError, len(ts))
+	for start := 0; start < len(ts); start += createBatchSize {
+		end := min(start+createBatchSize, len(ts))
+		if err := r.findConflicts(ctx, ts[start:end], out[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *gormRepository) findConflicts(ctx context.Context, ts []*Transaction, out []*DuplicateError) error {
+	ids := make([]string, 0, len(ts))
+	var refs [][]any
+	for _, t := range ts {
+		ids = append(ids, t.TransactionID)
+		if t.NoRef != "" {
+			refs = append(refs, []any{t.OrderTypeCode, t.NoRef})
+		}
+	}
+	cond := r.db.Where("transaction_id IN ?", ids)
+	if len(refs) > 0 {
+		cond = cond.Or("(order_type_code, no_ref) IN ?", refs)
+	}
+	var found []Transaction
+	// Unscoped: sama dengan FindConflict, baris di trash ikut dihitung
+	err := r.db.WithContext(ctx).Unscoped().
+		Select("transaction_id", "no_ref", "order_type_code").
+		Where(cond).
+		Find(&found).Error
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]bool, len(found))
+	byRef := make(map[string]string, len(found))
+	for _, f := range found {
+		byID[f.TransactionID] = true
+		if f.NoRef != "" {
+			byRef[refKey(f.OrderTypeCode, f.NoRef)] = f.TransactionID
+		}
+	}
+	for i, t := range ts {
+		switch {
+		case byID[t.TransactionID]:
+			out[i] = &DuplicateError{Field: "transaction_id", ConflictID: t.TransactionID}
+		case t.NoRef != "" && byRef[refKey(t.OrderTypeCode, t.NoRef)] != "":
+			out[i] = &DuplicateError{Field: "no_ref", ConflictID: byRef[refKey(t.OrderTypeCode, t.NoRef)]}
+		}
+	}
+	return nil
+}
+
+func (r *gormRepository) UpdateStatus(ctx context.Context, txID, from, to, reason string, at time.Time) (bool, error) {
+	var ok bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockByTxID(tx, txID)
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&Transaction{}).
+			Where("id = ? AND status = ?", before.ID, from).
+			Updates(map[string]any{
+				"status": to, "status_reason": reason, "status_changed_at": at, "updated_at": at,
+				"version": gorm.Expr("version + 1"),
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		ok = true
+		var after Transaction
+		if err := tx.First(&after, before.ID).Error; err != nil {
+			return err
+		}
+		return writeHistory(ctx, tx, ActionStatus, before, &after)
+	})
+	return ok && err == nil, err
+}
+
+// checkUnlinked: ErrLinkedAmount jika t reversal / refund atau sudah punya
+// turunan (termasuk yang di trash). Total turunan dihitung dari amount parent,
+// jadi amount & currency satu chain tidak boleh berubah.
+func checkUnlinked(tx *gorm.DB, t *Transaction) error {
+	if t.ParentTransactionID != "" {
+		return ErrLinkedAmount
+	}
+	var n int64
+	err := tx.Unscoped().Model(&Transaction{}).Where("parent_transaction_id = ?", t.TransactionID).Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrLinkedAmount
+	}
+	return nil
+}
+
+func (r *gormRepository) CreateLinked(ctx context.Context, parentTxID string, build func(parent *Transaction, returned decimal.Decimal) (*Transaction, error)) (*Transaction, error) {
+	var child *Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parent, err := lockByTxID(tx, parentTxID)
+		if err != nil {
+			return err
+		}
+		// aman dari race: refund lain untuk parent ini menunggu lock di atas
+		var returned decimal.Decimal
+		// termasuk turunan yang di-soft-delete: uangnya sudah kembali (aturan
+		// yang sama dengan Children / Chain.ReturnedAmount)
+		err = tx.Unscoped().Model(&Transaction{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("parent_transaction_id = ? AND status <> ?", parentTxID, StatusFailed).
+			Row().Scan(&returned)
+		if err != nil {
+			return err
+		}
+		before := *parent
+		if child, err = build(parent, returned); err != nil {
+			return err
+		}
+		child.Version = 1
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+		if err := writeHistory(ctx, tx, ActionCreate, nil, child); err != nil {
+			return err
+		}
+		if parent.Status == before.Status {
+			return nil
+		}
+		err = tx.Model(&Transaction{}).Where("id = ?", parent.ID).Updates(map[string]any{
+			"status":            parent.Status,
+			"status_reason":     parent.StatusReason,
+			"status_changed_at": parent.StatusChangedAt,
+			"updated_at":        parent.UpdatedAt,
+			"version":           gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		var after Transaction
+		if err := tx.First(&after, parent.ID).Error; err != nil {
+			return err
+		}
+		return writeHistory(ctx, tx, ActionStatus, &before, &after)
+	})
+	if err != nil {
+		if child != nil {
+			err = r.duplicate(ctx, child, err)
+		}
+		return nil, err
+	}
+	return child, nil
+}
+
+func (r *gormRepository) Children(ctx context.Context, parentTxID string) ([]Transaction, error) {
 	var items []Transaction
-	db := r.db.WithContext(ctx).Model(&Transaction{})
-	if !from.IsZero() {
-		db = db.Where("transaction_date >= ?", from)
+	// Unscoped: sama dengan total di CreateLinked
+	err := r.db.WithContext(ctx).Unscoped().Where("parent_transaction_id = ?", parentTxID).
+		Order("created_at ASC, id ASC").Find(&items).Error
+	return items, err
+}
+
+// DeleteByTxID: soft delete (deleted_at diisi, version naik).
+func (r *gormRepository) DeleteByTxID(ctx context.Context, txID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockByTxID(tx, txID)
+		if errors.Is(err, ErrNotFound) {
+			return nil // tetap idempotent seperti sebelumnya
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return r.setDeleted(ctx, tx, before, ActionDelete, map[string]any{"deleted_at": now, "updated_at": now})
+	})
+}
+
+// setDeleted menulis perubahan deleted_at + version dan history-nya;
+// t diisi ulang dengan baris terbaru.
+func (r *gormRepository) setDeleted(ctx context.Context, tx *gorm.DB, t *Transaction, action string, values map[string]any) error {
+	values["version"] = gorm.Expr("version + 1")
+	if err := tx.Unscoped().Model(&Transaction{}).Where("id = ?", t.ID).Updates(values).Error; err != nil {
+		return err
+	}
+	var after Transaction
+	if err := tx.Unscoped().First(&after, t.ID).Error; err != nil {
+		return err
+	}
+	before := *t
+	*t = after
+	return writeHistory(ctx, tx, action, &before, &after)
+}
+
+func (r *gormRepository) ListTrash(ctx context.Context, f Filter, page, size int) ([]Transaction, int64, error) {
+	var (
+		items []Transaction
+		total int64
+	)
+	order, err := orderFor(f, "deleted_at DESC, id DESC")
+	if err != nil {
+		return nil, 0, err
 	}
-	if !to.IsZero() {
-		db = db.Where("transaction_date <= ?", to)
+	db := applyFilter(r.db.WithContext(ctx).Unscoped().Model(&Transaction{}), f).Where("deleted_at IS NOT NULL")
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := db.Order(order).Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+		return nil, 0, err
 	}
-	if err := db.Order("transaction_date ASC").Find(&items).Error; err != nil {
+	return items, total, nil
+}
+
+// lockDeleted: seperti lockByTxID tapi termasuk baris di trash.
+func lockDeleted(tx *gorm.DB, txID string) (*Transaction, error) {
+	before, err := lockByTxID(tx.Unscoped(), txID)
+	if err != nil {
 		return nil, err
 	}
-	return items, nil
+	if !before.DeletedAt.Valid {
+		return nil, ErrNotDeleted
+	}
+	return before, nil
+}
+
+func (r *gormRepository) Restore(ctx context.Context, txID string, at time.Time) (*Transaction, error) {
+	var out *Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := lockDeleted(tx, txID)
+		if err != nil {
+			return err
+		}
+		if err := r.setDeleted(ctx, tx, t, ActionRestore, map[string]any{"deleted_at": nil, "updated_at": at}); err != nil {
+			return err
+		}
+		out = t
+		return nil
+	})
+	return out, err
+}
+
+func (r *gormRepository) Purge(ctx context.Context, txID string, deletedBefore time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := lockDeleted(tx, txID)
+		if err != nil {
+			return err
+		}
+		if t.DeletedAt.Time.After(deletedBefore) {
+			return fmt.Errorf("%w: deleted at %s", ErrRetention, t.DeletedAt.Time.Format(time.RFC3339))
+		}
+		var children int64
+		if err := tx.Unscoped().Model(&Transaction{}).Where("parent_transaction_id = ?", txID).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return fmt.Errorf("%w: %d reversal/refund reference this transaction", ErrHasChildren, children)
+		}
+		if err := tx.Unscoped().Delete(&Transaction{}, t.ID).Error; err != nil {
+			return err
+		}
+		return writeHistory(ctx, tx, ActionPurge, t, nil)
+	})
+}
+
+func (r *gormRepository) ListHistory(ctx context.Context, txID string, page, size int) ([]History, int64, error) {
+	var (
+		items []History
+		total int64
+	)
+	db := r.db.WithContext(ctx).Model(&History{}).Where("transaction_id = ?", txID)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("created_at ASC, id ASC").Offset((page - 1) * size).Limit(size).Find(&items).Error
+	return items, total, err
+}
+
+func (r *gormRepository) StreamHistory(ctx context.Context, txID string, fn func(*History) error) error {
+	rows, err := r.db.WithContext(ctx).Model(&History{}).Where("transaction_id = ?", txID).
+		Order("created_at ASC, id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h History
+		if err := r.db.ScanRows(rows, &h); err != nil {
+			return err
+		}
+		if err := fn(&h); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *gormRepository) Stream(ctx context.Context, f Filter, offset, limit int, fn func(*Transaction) error) error {
+	order, err := orderFor(f, "transaction_date ASC, id ASC")
+	if err != nil {
+		return err
+	}
+	db := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f).Order(order)
+	if offset > 0 {
+		db = db.Offset(offset)
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	return r.scanEach(db, fn)
+}
+
+// scanEach menjalankan query sebagai cursor dan memanggil fn per baris.
+func (r *gormRepository) scanEach(db *gorm.DB, fn func(*Transaction) error) error {
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var item Transaction
+	for rows.Next() {
+		item = Transaction{}
+		if err := r.db.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *gormRepository) Count(ctx context.Context, f Filter) (int64, error) {
+	var total int64
+	err := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f).Count(&total).Error
+	return total, err
+}
+
+func (r *gormRepository) CreateSnapshot(ctx context.Context, s *Snapshot, f Filter) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		order, err := orderFor(f, "transaction_date ASC, id ASC")
+		if err != nil {
+			return err
+		}
+		// baris dengan key grup yang sama harus kontigu: key dulu, baru sort
+		if expr, ok := groupKeyExpr[s.GroupBy]; ok && f.Sort != "" {
+			order = expr + " ASC, " + order
+		} else if o, ok := groupOrder[s.GroupBy]; ok {
+			order = o
+		}
+		sel := applyFilter(tx.Model(&Transaction{}), f).
+			Select("CAST(? AS varchar), row_number() OVER (ORDER BY "+order+"), id", s.ID)
+		res := tx.Exec("INSERT INTO export_snapshot_rows (snapshot_id, seq, transaction_pk) ?", sel)
+		if res.Error != nil {
+			return res.Error
+		}
+		s.RowCount = res.RowsAffected
+		return tx.Model(s).Update("row_count", s.RowCount).Error
+	})
+}
+
+func (r *gormRepository) GetSnapshot(ctx context.Context, id string) (*Snapshot, error) {
+	var out Snapshot
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&out).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *gormRepository) CountGroups(ctx context.Context, f Filter, groupBy string) (int64, error) {
+	expr, ok := groupKeyExpr[groupBy]
+	if !ok {
+		return 0, fmt.Errorf("unknown snapshot group %q", groupBy)
+	}
+	var n int64
+	err := applyFilter(r.db.WithContext(ctx).Model(&Transaction{}), f).
+		Select("COUNT(DISTINCT " + expr + ")").
+		Scan(&n).Error
+	return n, err
+}
+
+func (r *gormRepository) SnapshotGroups(ctx context.Context, s *Snapshot) ([]SnapshotPart, error) {
+	expr, ok := groupKeyExpr[s.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown snapshot group %q", s.GroupBy)
+	}
+	var groups []struct {
+		GroupKey string
+		StartSeq int64
+		EndSeq   int64
+	}
+	err := r.db.WithContext(ctx).Table("export_snapshot_rows sr").
+		Select(expr+" AS group_key, MIN(sr.seq) - 1 AS start_seq, MAX(sr.seq) AS end_seq").
+		Joins("JOIN transactions ON transactions.id = sr.transaction_pk").
+		Where("sr.snapshot_id = ?", s.ID).
+		Group("group_key").
+		Order("start_seq ASC").
+		Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]SnapshotPart, 0, len(groups))
+	for _, g := range groups {
+		parts = append(parts, SnapshotPart{Key: g.GroupKey, Start: g.StartSeq, End: g.EndSeq})
+	}
+	return parts, nil
+}
+
+func (r *gormRepository) SetSnapshotParts(ctx context.Context, id string, parts []SnapshotPart) error {
+	raw, err := json.Marshal(parts)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&Snapshot{}).Where("id = ?", id).Update("parts", datatypes.JSON(raw)).Error
+}
+
+func (r *gormRepository) StreamSnapshot(ctx context.Context, id string, start, end int64, fn func(*Transaction) error) error {
+	// Unscoped: isi snapshot tetap, walau transaksinya dihapus setelah snapshot dibuat
+	db := r.db.WithContext(ctx).Unscoped().Model(&Transaction{}).
+		Select("transactions.*").
+		Joins("JOIN export_snapshot_rows sr ON sr.transaction_pk = transactions.id").
+		Where("sr.snapshot_id = ? AND sr.seq > ? AND sr.seq <= ?", id, start, end).
+		Order("sr.seq ASC")
+	return r.scanEach(db, fn)
+}
+
+func (r *gormRepository) DeleteExpiredSnapshots(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&Snapshot{}).Select("id").Where("expires_at <= ?", now)
+		if err := tx.Where("snapshot_id IN (?)", expired).Delete(&SnapshotRow{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at <= ?", now).Delete(&Snapshot{}).Error
+	})
+}
+
+// applyFilter menerjemahkan Filter menjadi klausa WHERE.
+func applyFilter(db *gorm.DB, f Filter) *gorm.DB {
+	switch {
+	case !f.From.IsZero() && !f.To.IsZero():
+		db = db.Where("transaction_date BETWEEN ? AND ?", f.From, f.To)
+	case !f.From.IsZero():
+		db = db.Where("transaction_date >= ?", f.From)
+	case !f.To.IsZero():
+		db = db.Where("transaction_date <= ?", f.To)
+	}
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.Currency != "" {
+		db = db.Where("currency = ?", f.Currency)
+	}
+	if f.Method != "" {
+		db = db.Where("method = ?", f.Method)
+	}
+	if f.OrderTypeCode != "" {
+		db = db.Where("order_type_code = ?", f.OrderTypeCode)
+	}
+	if f.TransactionTypeCode != "" {
+		db = db.Where("transaction_type_code = ?", f.TransactionTypeCode)
+	}
+	if f.FromAccountNumber != "" {
+		db = db.Where("from_account_number = ?", f.FromAccountNumber)
+	}
+	if f.ToAccountNumber != "" {
+		db = db.Where("to_account_number = ?", f.ToAccountNumber)
+	}
+	if f.MinAmount != nil {
+		db = db.Where("amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		db = db.Where("amount <= ?", *f.MaxAmount)
+	}
+	for _, path := range sortedMetaPaths(f.Meta) {
+		// @> memakai index GIN jsonb_path_ops
+		docs := metaDocs(path, f.Meta[path])
+		conds := make([]string, len(docs))
+		args := make([]any, len(docs))
+		for i, doc := range docs {
+			conds[i] = "metadata @> ?::jsonb"
+			args[i] = doc
+		}
+		db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	if f.Query != "" {
+		if tsq, err := searchQuery(f.Query); err == nil {
+			db = db.Where("search_vector @@ to_tsquery('simple', ?)", tsq)
+		} else {
+			db = db.Where("FALSE")
+		}
+	}
+	return db
+}
+
+// orderFor: ORDER BY dari f.Sort (+ id sebagai tie-breaker agar urutan
+// deterministik), atau def jika Sort kosong.
+func orderFor(f Filter, def string) (string, error) {
+	order, err := f.orderBy()
+	if err != nil || order == "" {
+		return def, err
+	}
+	return order + ", id ASC", nil
 }
@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSearchTerms   = 10
+	maxSearchTermLen = 64
+
+	// penanda highlight dari ts_headline
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	headlineOpts   = "StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5, MaxFragments=2"
+)
+
+// searchQuery mengubah input bebas menjadi tsquery prefix, mis.
+// "budi sant" => "budi:* & sant:*". Hanya huruf & angka yang dipakai, jadi
+// operator tsquery dari user tidak pernah sampai ke Postgres.
+func searchQuery(q string) (string, error) {
+	terms := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) == 0 {
+		return "", errors.New("q must contain letters or digits")
+	}
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	for i, t := range terms {
+		if r := []rune(t); len(r) > maxSearchTermLen {
+			t = string(r[:maxSearchTermLen])
+		}
+		terms[i] = t + ":*"
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+// SearchHit adalah satu baris hasil Repository.Search.
+type SearchHit struct {
+	Transaction
+	Rank              float64
+	HlNoRef           string
+	HlFromAccountName string
+	HlToAccountName   string
+	HlDescription     string
+}
+
+// SearchResult DTO: transaksi + skor relevansi + potongan teks yang cocok.
+type SearchResult struct {
+	Response
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+func ToSearchResult(h *SearchHit) SearchResult {
+	res := SearchResult{Response: ToResponse(&h.Transaction), Rank: h.Rank, Highlights: map[string]string{}}
+	for field, hl := range map[string]string{
+		"no_ref":            h.HlNoRef,
+		"from_account_name": h.HlFromAccountName,
+		"to_account_name":   h.HlToAccountName,
+		"description":       h.HlDescription,
+	} {
+		if strings.Contains(hl, highlightStart) {
+			res.Highlights[field] = escapeHighlight(hl)
+		}
+	}
+	return res
+}
+
+// escapeHighlight meng-escape HTML dari data transaksi, kecuali tag <mark>
+// yang disisipkan ts_headline.
+func escapeHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, html.EscapeString(highlightStart), highlightStart)
+	return strings.ReplaceAll(s, html.EscapeString(highlightStop), highlightStop)
+}
@@ -1,27 +1,128 @@
 package transaction
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type Service interface {
 	Create(ctx context.Context, in CreateRequest) (Response, error)
+	// BulkCreate: create banyak transaksi (maks MaxBulkItems). Tiap item
+	// divalidasi seperti Create; error & duplikat (di DB maupun dengan item
+	// sebelumnya) dilaporkan per item. atomic => satu item gagal membatalkan
+	// semuanya (lihat BulkResult.Rejected). ctx habis setelah sebagian chunk
+	// tersimpan (non-atomic) => hasil sejauh ini (BulkResult.Incomplete)
+	// beserta ctx.Err().
+	BulkCreate(ctx context.Context, items []CreateRequest, atomic bool) (BulkResult, error)
+	// Upsert (import CSV): transaction_id sudah ada => seluruh field ditimpa
+	// dengan aturan yang sama dengan Update, selain itu Create. Lihat
+	// UpsertRequest untuk status REVERSED, parent_transaction_id dan kind.
+	Upsert(ctx context.Context, in UpsertRequest) (UpsertResult, error)
+	// DryRun menjalankan fn dengan Service yang semua tulisannya berada di
+	// satu transaksi DB yang selalu di-rollback: validasi, cek duplikat dan
+	// state machine berjalan penuh, tetapi tidak ada yang tersimpan.
+	DryRun(ctx context.Context, fn func(Service) error) error
 	Get(ctx context.Context, txID string) (Response, error)
-	List(ctx context.Context, page, size int) ([]Response, int, int64, error)
-	Update(ctx context.Context, txID string, in UpdateRequest) (Response, error)
+	// List: f sama dengan filter export, jadi list == isi file export.
+	List(ctx context.Context, f Filter, page, size int) ([]Response, int, int64, error)
+	// ListCursor: keyset pagination (created_at, id) tanpa COUNT, kecuali
+	// withTotal. cursor kosong = halaman pertama.
+	ListCursor(ctx context.Context, f Filter, cursor string, size int, withTotal bool) (CursorPage, error)
+	// Search: f.Query wajib; hasil urut relevansi dengan highlight.
+	Search(ctx context.Context, f Filter, page, size int) ([]SearchResult, int64, error)
+	// Update: version > 0 => hanya jika transaksi masih di versi tsb (If-Match),
+	// selain itu ErrVersionConflict. Update bersamaan tidak saling menimpa.
+	Update(ctx context.Context, txID string, in UpdateRequest, version int64) (Response, error)
+	// Complete / Fail memindahkan transaksi PENDING ke SUCCESS / FAILED
+	// (lihat transitions) dan mencatat alasan & waktunya.
+	Complete(ctx context.Context, txID string, in TransitionRequest) (Response, error)
+	Fail(ctx context.Context, txID string, in TransitionRequest) (Response, error)
+	// Reverse membatalkan sisa amount transaksi SUCCESS (status => REVERSED);
+	// Refund mengembalikan sebagian. Keduanya membuat transaksi baru yang
+	// menunjuk ke parent dengan rekening asal & tujuan ditukar.
+	Reverse(ctx context.Context, txID string, in ReverseRequest) (Response, error)
+	Refund(ctx context.Context, txID string, in RefundRequest) (Response, error)
+	// Delete: soft delete; transaksi hilang dari get/list/search/export/update.
 	Delete(ctx context.Context, txID string) error
+	Trash(ctx context.Context, f Filter, page, size int) ([]Response, int64, error)
+	Restore(ctx context.Context, txID string) (Response, error)
+	// Purge: hard delete transaksi di trash setelah Config.PurgeRetention.
+	Purge(ctx context.Context, txID string) error
+
+	// History: audit trail txID urut waktu (tetap ada setelah transaksi
+	// dihapus). Tanpa entri: transaksi ada => list kosong, tidak ada (juga
+	// di trash) => ErrNotFound.
+	History(ctx context.Context, txID string, page, size int) ([]HistoryResponse, int64, error)
+	ExportHistory(ctx context.Context, txID string, fn func(HistoryResponse) error) error
+
+	// Export men-stream transaksi yang cocok dengan filter (urut f.Sort,
+	// default transaction_date) ke fn tanpa menampung seluruh hasil di memori.
+	// limit <= 0 berarti tanpa batas.
+	Export(ctx context.Context, f Filter, offset, limit int, fn func(Response) error) error
+	Count(ctx context.Context, f Filter) (int64, error)
+
+	// Snapshot export untuk manifest multi-part (lihat Snapshot).
+	// groupBy: "" (urut tanggal) atau salah satu GroupBy*.
+	CreateSnapshot(ctx context.Context, f Filter, groupBy string, ttl time.Duration) (*Snapshot, error)
+	GetSnapshot(ctx context.Context, id string) (*Snapshot, error)
+	// CountGroups: jumlah part yang akan dihasilkan SnapshotGroups untuk
+	// filter & groupBy ini (tanpa snapshot).
+	CountGroups(ctx context.Context, f Filter, groupBy string) (int64, error)
+	SnapshotGroups(ctx context.Context, snap *Snapshot) ([]SnapshotPart, error)
+	SetSnapshotParts(ctx context.Context, id string, parts []SnapshotPart) error
+	ExportSnapshot(ctx context.Context, id string, part SnapshotPart, fn func(Response) error) error
+}
+type Config struct {
+	// PurgeRetention: minimal lama transaksi berada di trash sebelum boleh
+	// di-purge (hard delete). <= 0 => DefaultPurgeRetention.
+	PurgeRetention time.Duration
+}
+
+const DefaultPurgeRetention = 30 * 24 * time.Hour
+
+type service struct {
+	repo Repository
+	cfg  Config
 }
-type service struct{ repo Repository }
 
-func NewService(repo Repository) Service { return &service{repo: repo} }
+func NewService(repo Repository, cfg Config) Service {
+	if cfg.PurgeRetention <= 0 {
+		cfg.PurgeRetention = DefaultPurgeRetention
+	}
+	return &service{repo: repo, cfg: cfg}
+}
 
 func (s *service) Create(ctx context.Context, in CreateRequest) (Response, error) {
 	if err := ValidateCreate(in); err != nil {
 		return Response{}, err
 	}
-	entity := &Transaction{
-		TransactionID:          in.TransactionID,
+	entity := newTransaction(in)
+	if err := s.checkUnique(ctx, entity); err != nil {
+		return Response{}, err
+	}
+	if err := s.repo.Create(ctx, entity); err != nil {
+		return Response{}, err
+	}
+	return ToResponse(entity), nil
+}
+
+// newTransaction: entity dari CreateRequest yang sudah lolos ValidateCreate.
+func newTransaction(in CreateRequest) *Transaction {
+	txID := newTransactionID()
+	if in.TransactionID != "" {
+		txID, _ = normalizeTransactionID(in.TransactionID) // sudah divalidasi
+	}
+	return &Transaction{
+		TransactionID:          txID,
 		NoRef:                  in.NoRef,
 		OrderTypeCode:          in.OrderTypeCode,
 		OrderTypeName:          in.OrderTypeName,
@@ -41,10 +142,150 @@ func (s *service) Create(ctx context.Context, in CreateRequest) (Response, error
 		Currency:               in.Currency,
 		Metadata:               in.Metadata,
 	}
-	if err := s.repo.Create(ctx, entity); err != nil {
-		return Response{}, err
+}
+
+func (s *service) BulkCreate(ctx context.Context, items []CreateRequest, atomic bool) (BulkResult, error) {
+	res := BulkResult{Summary: BulkSummary{Atomic: atomic}, Items: make([]BulkItemResult, len(items))}
+
+	// 1. validasi + duplikat di dalam batch (item pertama yang menang)
+	var (
+		pending []*Transaction
+		index   []int // posisi item untuk pending[i]
+		seenID  = map[string]bool{}
+		seenRef = map[string]string{}
+	)
+	for i, in := range items {
+		it := &res.Items[i]
+		it.Index = i
+		if err := ValidateCreate(in); err != nil {
+			it.fail(err)
+			continue
+		}
+		t := newTransaction(in)
+		it.TransactionID = t.TransactionID
+		key := refKey(t.OrderTypeCode, t.NoRef)
+		switch {
+		case seenID[t.TransactionID]:
+			it.fail(&DuplicateError{Field: "transaction_id", ConflictID: t.TransactionID})
+			continue
+		case t.NoRef != "" && seenRef[key] != "":
+			it.fail(&DuplicateError{Field: "no_ref", ConflictID: seenRef[key]})
+			continue
+		}
+		seenID[t.TransactionID] = true
+		if t.NoRef != "" {
+			seenRef[key] = t.TransactionID
+		}
+		pending = append(pending, t)
+		index = append(index, i)
 	}
-	return ToResponse(entity), nil
+
+	// 2. duplikat terhadap data di DB
+	pending, index, err := s.dropConflicts(ctx, &res, pending, index)
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	// 3. simpan
+	if atomic {
+		err = s.createAtomic(ctx, &res, pending, index)
+	} else {
+		err = s.createChunks(ctx, &res, pending, index)
+	}
+	res.summarize()
+	if err != nil && !res.Incomplete() {
+		return BulkResult{}, err
+	}
+	return res, err
+}
+
+// dropConflicts menandai item yang bentrok dengan data di DB sebagai
+// duplicate dan mengembalikan sisanya.
+func (s *service) dropConflicts(ctx context.Context, res *BulkResult, pending []*Transaction, index []int) ([]*Transaction, []int, error) {
+	if len(pending) == 0 {
+		return pending, index, nil
+	}
+	dups, err := s.repo.FindConflicts(ctx, pending)
+	if err != nil {
+		return nil, nil, err
+	}
+	keptT, keptI := pending[:0], index[:0]
+	for i, dup := range dups {
+		if dup != nil {
+			res.Items[index[i]].fail(dup)
+			continue
+		}
+		keptT, keptI = append(keptT, pending[i]), append(keptI, index[i])
+	}
+	return keptT, keptI, nil
+}
+
+// createAtomic: satu transaksi DB untuk semua item; ada item gagal =>
+// tidak ada yang disimpan dan item valid ditandai skipped.
+func (s *service) createAtomic(ctx context.Context, res *BulkResult, pending []*Transaction, index []int) error {
+	failed := len(pending) < len(res.Items)
+	if !failed {
+		err := s.repo.CreateBatch(ctx, pending)
+		if errors.Is(err, ErrDuplicate) {
+			// balapan dengan request lain setelah pengecekan: cari item-nya
+			n := len(pending)
+			if pending, index, err = s.dropConflicts(ctx, res, pending, index); err != nil {
+				return err
+			}
+			if len(pending) == n {
+				return ErrDuplicate
+			}
+			failed = true
+		} else if err != nil {
+			return err
+		}
+	}
+	for _, i := range index {
+		if failed {
+			res.Items[i].Status = BulkSkipped
+		} else {
+			res.Items[i].Status = BulkCreated
+		}
+	}
+	return nil
+}
+
+// createChunks: CreateBatch per bulkChunkSize item; chunk yang gagal diulang
+// per item sehingga hanya item bermasalah yang tidak tersimpan. ctx habis =>
+// item yang belum tersimpan ditandai not_attempted.
+func (s *service) createChunks(ctx context.Context, res *BulkResult, pending []*Transaction, index []int) error {
+	stop := func(from int) error {
+		for _, i := range index[from:] {
+			res.Items[i].Status = BulkNotAttempted
+		}
+		return ctx.Err()
+	}
+	for start := 0; start < len(pending); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(pending))
+		if ctx.Err() != nil {
+			return stop(start)
+		}
+		if err := s.repo.CreateBatch(ctx, pending[start:end]); err == nil {
+			for _, i := range index[start:end] {
+				res.Items[i].Status = BulkCreated
+			}
+			continue
+		}
+		for j := start; j < end; j++ {
+			if ctx.Err() != nil {
+				return stop(j)
+			}
+			if err := s.repo.Create(ctx, pending[j]); err != nil {
+				if ctx.Err() != nil {
+					return stop(j) // gagal karena timeout, bukan karena item-nya
+				}
+				res.Items[index[j]].fail(err)
+				continue
+			}
+			res.Items[index[j]].Status = BulkCreated
+		}
+	}
+	return nil
 }
 
 func (s *service) Get(ctx context.Context, txID string) (Response, error) {
@@ -53,13 +294,40 @@ func (s *service) Get(ctx context.Context, txID string) (Response, error) {
 		return Response{}, err
 	}
 	if found == nil {
-		return Response{}, errors.New("not_found")
+		return Response{}, ErrNotFound
 	}
-	return ToResponse(found), nil
+	res := ToResponse(found)
+	if res.Chain, err = s.chain(ctx, found); err != nil {
+		return Response{}, err
+	}
+	return res, nil
+}
+
+// chain: nil jika transaksi tidak punya parent maupun turunan.
+func (s *service) chain(ctx context.Context, t *Transaction) (*Chain, error) {
+	root := t
+	if t.ParentTransactionID != "" {
+		parent, err := s.repo.GetByTxID(ctx, t.ParentTransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil { // parent sudah dihapus
+			return nil, nil
+		}
+		root = parent
+	}
+	children, err := s.repo.Children(ctx, root.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return nil, nil
+	}
+	return buildChain(root, children), nil
 }
 
-func (s *service) List(ctx context.Context, page, size int) ([]Response, int, int64, error) {
-	items, total, err := s.repo.List(ctx, page, size)
+func (s *service) List(ctx context.Context, f Filter, page, size int) ([]Response, int, int64, error) {
+	items, total, err := s.repo.List(ctx, f, page, size)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -70,7 +338,48 @@ func (s *service) List(ctx context.Context, page, size int) ([]Response, int, in
 	return out, page, total, nil
 }
 
-func (s *service) Update(ctx context.Context, txID string, in UpdateRequest) (Response, error) {
+func (s *service) ListCursor(ctx context.Context, f Filter, cursor string, size int, withTotal bool) (CursorPage, error) {
+	after, err := decodeCursor(cursor, f)
+	if err != nil {
+		return CursorPage{}, err
+	}
+	// ambil satu baris lebih untuk tahu ada halaman berikutnya atau tidak
+	items, err := s.repo.ListAfter(ctx, f, after, size+1)
+	if err != nil {
+		return CursorPage{}, err
+	}
+	var page CursorPage
+	if len(items) > size {
+		items = items[:size]
+		page.NextCursor = encodeCursor(&items[len(items)-1], f)
+	}
+	page.Items = make([]Response, 0, len(items))
+	for i := range items {
+		page.Items = append(page.Items, ToResponse(&items[i]))
+	}
+	if withTotal {
+		total, err := s.repo.Count(ctx, f)
+		if err != nil {
+			return CursorPage{}, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
+
+func (s *service) Search(ctx context.Context, f Filter, page, size int) ([]SearchResult, int64, error) {
+	hits, total, err := s.repo.Search(ctx, f, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]SearchResult, 0, len(hits))
+	for i := range hits {
+		out = append(out, ToSearchResult(&hits[i]))
+	}
+	return out, total, nil
+}
+
+func (s *service) Update(ctx context.Context, txID string, in UpdateRequest, version int64) (Response, error) {
 	if err := in.Validate(); err != nil {
 		return Response{}, err
 	}
@@ -79,9 +388,23 @@ func (s *service) Update(ctx context.Context, txID string, in UpdateRequest) (Re
 		return Response{}, err
 	}
 	if found == nil {
-		return Response{}, errors.New("not_found")
+		return Response{}, ErrNotFound
+	}
+	if version > 0 && version != found.Version {
+		return Response{}, ErrVersionConflict
 	}
-	// patch
+	if err := s.patch(ctx, found, in); err != nil {
+		return Response{}, err
+	}
+	if err := s.repo.Update(ctx, found); err != nil {
+		return Response{}, err
+	}
+	return ToResponse(found), nil
+}
+
+// patch menerapkan field in yang diisi ke found: transisi status, skala
+// amount dan keunikan no_ref ikut dicek. Belum ditulis ke DB.
+func (s *service) patch(ctx context.Context, found *Transaction, in UpdateRequest) error {
 	if in.NoRef != nil {
 		found.NoRef = *in.NoRef
 	}
@@ -121,8 +444,14 @@ func (s *service) Update(ctx context.Context, txID string, in UpdateRequest) (Re
 	if in.Amount != nil {
 		found.Amount = *in.Amount
 	}
-	if in.Status != nil {
+	if in.Status != nil && *in.Status != found.Status {
+		if err := checkTransition(found.Status, *in.Status); err != nil {
+			return err
+		}
+		now := time.Now()
 		found.Status = *in.Status
+		found.StatusReason = ""
+		found.StatusChangedAt = &now
 	}
 	if in.Description != nil {
 		found.Description = *in.Description
@@ -136,13 +465,352 @@ func (s *service) Update(ctx context.Context, txID string, in UpdateRequest) (Re
 	if in.Metadata != nil {
 		found.Metadata = *in.Metadata
 	}
+	// skala amount bergantung currency, jadi dicek setelah patch
+	if in.Amount != nil || in.Currency != nil {
+		if err := validateAmount(found.Amount, found.Currency); err != nil {
+			return err
+		}
+	}
 
-	if err := s.repo.Update(ctx, found); err != nil {
+	if in.NoRef != nil || in.OrderTypeCode != nil {
+		if err := s.checkUnique(ctx, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Action UpsertResult.
+const (
+	UpsertCreated   = "created"
+	UpsertUpdated   = "updated"
+	UpsertUnchanged = "unchanged" // tidak ditulis, version tetap
+)
+
+// UpsertRequest: satu baris import. ParentTransactionID / Kind (nil = kolom
+// tidak ada di file) tidak bisa diubah, hanya dicocokkan dengan baris yang
+// ada. Reversal / refund dan status REVERSED hanya dibuat lewat Reverse /
+// Refund, jadi baris export seperti itu hanya valid jika transaksinya sudah
+// ada dengan nilai yang sama.
+type UpsertRequest struct {
+	CreateRequest
+	ParentTransactionID *string
+	Kind                *string
+}
+
+// UpsertResult: hasil Upsert. Changes = diff terhadap baris lama (updated).
+type UpsertResult struct {
+	Action      string
+	Transaction Response
+	Changes     map[string]FieldChange
+}
+
+func (s *service) Upsert(ctx context.Context, in UpsertRequest) (UpsertResult, error) {
+	check := in.CreateRequest
+	if check.Status == StatusReversed {
+		check.Status = StatusSuccess // REVERSED dicek terhadap baris lama di bawah
+	}
+	if err := ValidateCreate(check); err != nil {
+		return UpsertResult{}, err
+	}
+	if in.TransactionID != "" {
+		txID, _ := normalizeTransactionID(in.TransactionID)
+		found, err := s.repo.GetByTxID(ctx, txID)
+		if err != nil {
+			return UpsertResult{}, err
+		}
+		if found != nil {
+			if err := checkUpsertLink(found, in); err != nil {
+				return UpsertResult{}, err
+			}
+			before := *found
+			if err := s.patch(ctx, found, in.asUpdate()); err != nil {
+				return UpsertResult{}, err
+			}
+			// waktu yang sama di zona lain (mis. CSV dari env lain) bukan perubahan
+			if found.TransactionDate.Equal(before.TransactionDate) {
+				found.TransactionDate = before.TransactionDate
+			}
+			changes := diffTransactions(&before, found)
+			if len(changes) == 0 {
+				return UpsertResult{Action: UpsertUnchanged, Transaction: ToResponse(&before)}, nil
+			}
+			if err := s.repo.Update(ctx, found); err != nil {
+				return UpsertResult{}, err
+			}
+			return UpsertResult{Action: UpsertUpdated, Transaction: ToResponse(found), Changes: changes}, nil
+		}
+	}
+	if err := checkUpsertLink(&Transaction{}, in); err != nil {
+		return UpsertResult{}, err
+	}
+	res, err := s.Create(ctx, in.CreateRequest)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	return UpsertResult{Action: UpsertCreated, Transaction: res}, nil
+}
+
+// checkUpsertLink: field yang hanya diubah Reverse / Refund harus sama dengan
+// baris yang ada (found kosong = transaksi baru).
+func checkUpsertLink(found *Transaction, in UpsertRequest) error {
+	if in.Status == StatusReversed && found.Status != StatusReversed {
+		return fmt.Errorf("%w: %s -> %s (use reverse)", ErrInvalidTransition, cmp.Or(found.Status, "new"), StatusReversed)
+	}
+	if in.ParentTransactionID != nil && *in.ParentTransactionID != found.ParentTransactionID {
+		if found.ID == 0 {
+			return fmt.Errorf("%w: reversal/refund can only be created by reverse/refund (parent_transaction_id %s)", ErrReadOnlyField, *in.ParentTransactionID)
+		}
+		return fmt.Errorf("%w: parent_transaction_id is %q, got %q", ErrReadOnlyField, found.ParentTransactionID, *in.ParentTransactionID)
+	}
+	if in.Kind != nil && *in.Kind != found.Kind {
+		if found.ID == 0 {
+			return fmt.Errorf("%w: reversal/refund can only be created by reverse/refund (kind %s)", ErrReadOnlyField, *in.Kind)
+		}
+		return fmt.Errorf("%w: kind is %q, got %q", ErrReadOnlyField, found.Kind, *in.Kind)
+	}
+	return nil
+}
+
+func (s *service) DryRun(ctx context.Context, fn func(Service) error) error {
+	return s.repo.DryRun(ctx, func(repo Repository) error {
+		return fn(&service{repo: repo, cfg: s.cfg})
+	})
+}
+
+func (s *service) Complete(ctx context.Context, txID string, in TransitionRequest) (Response, error) {
+	return s.transition(ctx, txID, StatusSuccess, in)
+}
+
+func (s *service) Fail(ctx context.Context, txID string, in TransitionRequest) (Response, error) {
+	if strings.TrimSpace(in.Reason) == "" {
+		return Response{}, errors.New("reason is required")
+	}
+	return s.transition(ctx, txID, StatusFailed, in)
+}
+
+// transition: cek state machine lalu update atomik (WHERE status = lama),
+// sehingga dua request bersamaan tidak bisa sama-sama berhasil.
+func (s *service) transition(ctx context.Context, txID, to string, in TransitionRequest) (Response, error) {
+	if err := validate.Struct(in); err != nil {
+		return Response{}, err
+	}
+	found, err := s.repo.GetByTxID(ctx, txID)
+	if err != nil {
+		return Response{}, err
+	}
+	if found == nil {
+		return Response{}, ErrNotFound
+	}
+	if err := checkTransition(found.Status, to); err != nil {
 		return Response{}, err
 	}
+	reason := strings.TrimSpace(in.Reason)
+	now := time.Now()
+	ok, err := s.repo.UpdateStatus(ctx, txID, found.Status, to, reason, now)
+	if err != nil {
+		return Response{}, err
+	}
+	if !ok {
+		// status berubah di antara read & update
+		return Response{}, fmt.Errorf("%w: status changed concurrently", ErrInvalidTransition)
+	}
+	found.Status, found.StatusReason, found.StatusChangedAt, found.UpdatedAt = to, reason, &now, now
+	found.Version++
 	return ToResponse(found), nil
 }
 
+func (s *service) Reverse(ctx context.Context, txID string, in ReverseRequest) (Response, error) {
+	if err := validate.Struct(in); err != nil {
+		return Response{}, err
+	}
+	reason := strings.TrimSpace(in.Reason)
+	return s.createLinked(ctx, txID, func(parent *Transaction, returned decimal.Decimal, now time.Time) (*Transaction, error) {
+		if err := checkTransition(parent.Status, StatusReversed); err != nil {
+			return nil, err
+		}
+		remaining := parent.Amount.Sub(returned)
+		if !remaining.IsPositive() {
+			return nil, fmt.Errorf("%w: nothing left to reverse", ErrRefundExceeded)
+		}
+		parent.Status, parent.StatusReason, parent.StatusChangedAt, parent.UpdatedAt = StatusReversed, reason, &now, now
+		return newLinked(parent, KindReversal, remaining, in.NoRef, reason, now), nil
+	})
+}
+
+func (s *service) Refund(ctx context.Context, txID string, in RefundRequest) (Response, error) {
+	if err := validate.Struct(in); err != nil {
+		return Response{}, err
+	}
+	return s.createLinked(ctx, txID, func(parent *Transaction, returned decimal.Decimal, now time.Time) (*Transaction, error) {
+		if parent.Status != StatusSuccess {
+			return nil, fmt.Errorf("%w: cannot refund %s transaction", ErrInvalidTransition, parent.Status)
+		}
+		if err := validateAmount(in.Amount, parent.Currency); err != nil {
+			return nil, err
+		}
+		if remaining := parent.Amount.Sub(returned); in.Amount.GreaterThan(remaining) {
+			return nil, fmt.Errorf("%w: remaining %s", ErrRefundExceeded, FormatAmount(remaining, parent.Currency))
+		}
+		return newLinked(parent, KindRefund, in.Amount, in.NoRef, strings.TrimSpace(in.Reason), now), nil
+	})
+}
+
+// createLinked: reversal / refund dari transaksi turunan tidak diizinkan.
+// Hasilnya dikembalikan lengkap dengan Chain.
+func (s *service) createLinked(ctx context.Context, txID string, build func(parent *Transaction, returned decimal.Decimal, now time.Time) (*Transaction, error)) (Response, error) {
+	now := time.Now()
+	child, err := s.repo.CreateLinked(ctx, txID, func(parent *Transaction, returned decimal.Decimal) (*Transaction, error) {
+		if parent.ParentTransactionID != "" {
+			return nil, fmt.Errorf("%w: %s is already a %s", ErrInvalidTransition, parent.TransactionID, strings.ToLower(parent.Kind))
+		}
+		t, err := build(parent, returned, now)
+		if err != nil {
+			return nil, err
+		}
+		t.TransactionID = newTransactionID()
+		if t.NoRef != "" {
+			if err := s.checkUnique(ctx, t); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return s.Get(ctx, child.TransactionID)
+}
+
+// checkUnique: cek awal agar client mendapat transaction_id yang bentrok.
+// Insert bersamaan tetap ditangkap unique index di DB (lihat repository).
+func (s *service) checkUnique(ctx context.Context, t *Transaction) error {
+	dup, err := s.repo.FindConflict(ctx, t)
+	if err != nil {
+		return err
+	}
+	if dup != nil {
+		return dup
+	}
+	return nil
+}
+
 func (s *service) Delete(ctx context.Context, txID string) error {
 	return s.repo.DeleteByTxID(ctx, txID)
 }
+
+func (s *service) Trash(ctx context.Context, f Filter, page, size int) ([]Response, int64, error) {
+	items, total, err := s.repo.ListTrash(ctx, f, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]Response, 0, len(items))
+	for i := range items {
+		out = append(out, ToResponse(&items[i]))
+	}
+	return out, total, nil
+}
+
+func (s *service) Restore(ctx context.Context, txID string) (Response, error) {
+	t, err := s.repo.Restore(ctx, txID, time.Now())
+	if err != nil {
+		return Response{}, err
+	}
+	return ToResponse(t), nil
+}
+
+func (s *service) Purge(ctx context.Context, txID string) error {
+	err := s.repo.Purge(ctx, txID, time.Now().Add(-s.cfg.PurgeRetention))
+	if errors.Is(err, ErrRetention) {
+		return fmt.Errorf("%w (retention %s)", err, s.cfg.PurgeRetention)
+	}
+	return err
+}
+
+func (s *service) History(ctx context.Context, txID string, page, size int) ([]HistoryResponse, int64, error) {
+	items, total, err := s.repo.ListHistory(ctx, txID, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		// transaksi tanpa history (mis. dibuat sebelum audit trail) => list
+		// kosong; 404 hanya jika transaksinya memang tidak ada
+		ok, err := s.repo.Exists(ctx, txID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, ErrNotFound
+		}
+	}
+	out := make([]HistoryResponse, 0, len(items))
+	for i := range items {
+		out = append(out, ToHistoryResponse(&items[i]))
+	}
+	return out, total, nil
+}
+
+func (s *service) ExportHistory(ctx context.Context, txID string, fn func(HistoryResponse) error) error {
+	return s.repo.StreamHistory(ctx, txID, func(h *History) error {
+		return fn(ToHistoryResponse(h))
+	})
+}
+
+func (s *service) Export(ctx context.Context, f Filter, offset, limit int, fn func(Response) error) error {
+	return s.repo.Stream(ctx, f, offset, limit, func(t *Transaction) error {
+		return fn(ToResponse(t))
+	})
+}
+
+func (s *service) Count(ctx context.Context, f Filter) (int64, error) {
+	return s.repo.Count(ctx, f)
+}
+
+func (s *service) CreateSnapshot(ctx context.Context, f Filter, groupBy string, ttl time.Duration) (*Snapshot, error) {
+	// bersihkan snapshot lama secara oportunistik
+	now := time.Now()
+	if err := s.repo.DeleteExpiredSnapshots(ctx, now); err != nil {
+		log.Printf("snapshot: cleanup expired: %v", err)
+	}
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{ID: uuid.NewString(), Filter: raw, GroupBy: groupBy, ExpiresAt: now.Add(ttl)}
+	if err := s.repo.CreateSnapshot(ctx, snap, f); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (s *service) GetSnapshot(ctx context.Context, id string) (*Snapshot, error) {
+	snap, err := s.repo.GetSnapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil || snap.Expired(time.Now()) {
+		return nil, errors.New("snapshot_not_found")
+	}
+	return snap, nil
+}
+
+func (s *service) CountGroups(ctx context.Context, f Filter, groupBy string) (int64, error) {
+	return s.repo.CountGroups(ctx, f, groupBy)
+}
+
+func (s *service) SnapshotGroups(ctx context.Context, snap *Snapshot) ([]SnapshotPart, error) {
+	if snap.GroupBy == "" {
+		return nil, errors.New("snapshot is not grouped")
+	}
+	return s.repo.SnapshotGroups(ctx, snap)
+}
+
+func (s *service) SetSnapshotParts(ctx context.Context, id string, parts []SnapshotPart) error {
+	return s.repo.SetSnapshotParts(ctx, id, parts)
+}
+
+func (s *service) ExportSnapshot(ctx context.Context, id string, part SnapshotPart, fn func(Response) error) error {
+	return s.repo.StreamSnapshot(ctx, id, part.Start, part.End, func(t *Transaction) error {
+		return fn(ToResponse(t))
+	})
+}
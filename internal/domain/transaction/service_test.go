@@ -0,0 +1,271 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// memRepo: Repository di memori untuk test service (hanya method yang dipakai).
+type memRepo struct {
+	Repository
+	rows   map[string]*Transaction
+	nextID uint
+}
+
+func newMemRepo(ts ...*Transaction) *memRepo {
+	r := &memRepo{rows: map[string]*Transaction{}}
+	for _, t := range ts {
+		r.put(t)
+	}
+	return r
+}
+
+func (r *memRepo) put(t *Transaction) {
+	if t.ID == 0 {
+		r.nextID++
+		t.ID = r.nextID
+	}
+	if t.Version == 0 {
+		t.Version = 1
+	}
+	c := *t
+	r.rows[t.TransactionID] = &c
+}
+
+func (r *memRepo) GetByTxID(_ context.Context, txID string) (*Transaction, error) {
+	t, ok := r.rows[txID]
+	if !ok {
+		return nil, nil
+	}
+	c := *t
+	return &c, nil
+}
+
+func (r *memRepo) FindConflict(_ context.Context, t *Transaction) (*DuplicateError, error) {
+	for _, o := range r.rows {
+		switch {
+		case o.ID == t.ID:
+		case o.TransactionID == t.TransactionID:
+			return &DuplicateError{Field: "transaction_id", ConflictID: o.TransactionID}, nil
+		case t.NoRef != "" && o.NoRef == t.NoRef && o.OrderTypeCode == t.OrderTypeCode:
+			return &DuplicateError{Field: "no_ref", ConflictID: o.TransactionID}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memRepo) Create(_ context.Context, t *Transaction) error {
+	r.put(t)
+	return nil
+}
+
+func (r *memRepo) Update(_ context.Context, t *Transaction) error {
+	before := r.rows[t.TransactionID]
+	if before.Version != t.Version {
+		return ErrVersionConflict
+	}
+	// aturan yang sama dengan checkUnlinked di repository gorm
+	if !t.Amount.Equal(before.Amount) || !strings.EqualFold(t.Currency, before.Currency) {
+		if before.ParentTransactionID != "" {
+			return ErrLinkedAmount
+		}
+		for _, c := range r.rows {
+			if c.ParentTransactionID == before.TransactionID {
+				return ErrLinkedAmount
+			}
+		}
+	}
+	t.Version++
+	r.put(t)
+	return nil
+}
+
+func (r *memRepo) UpdateStatus(_ context.Context, txID, from, to, reason string, at time.Time) (bool, error) {
+	t := r.rows[txID]
+	if t == nil || t.Status != from {
+		return false, nil
+	}
+	t.Status, t.StatusReason, t.StatusChangedAt = to, reason, &at
+	t.Version++
+	return true, nil
+}
+
+func (r *memRepo) CreateLinked(_ context.Context, parentTxID string, build func(parent *Transaction, returned decimal.Decimal) (*Transaction, error)) (*Transaction, error) {
+	stored, ok := r.rows[parentTxID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var returned decimal.Decimal
+	for _, c := range r.rows {
+		if c.ParentTransactionID == parentTxID && c.Status != StatusFailed {
+			returned = returned.Add(c.Amount)
+		}
+	}
+	parent := *stored
+	child, err := build(&parent, returned)
+	if err != nil {
+		return nil, err
+	}
+	r.put(child)
+	r.put(&parent)
+	return child, nil
+}
+
+func (r *memRepo) Children(_ context.Context, parentTxID string) ([]Transaction, error) {
+	var out []Transaction
+	for _, c := range r.rows {
+		if c.ParentTransactionID == parentTxID {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (r *memRepo) ListAfter(_ context.Context, _ Filter, after *Cursor, limit int) ([]Transaction, error) {
+	// urut (created_at, id) DESC seperti repository gorm
+	var out []Transaction
+	for id := r.nextID; id > 0 && len(out) < limit; id-- {
+		for _, t := range r.rows {
+			if t.ID == id && (after == nil || t.ID < after.ID) {
+				out = append(out, *t)
+			}
+		}
+	}
+	return out, nil
+}
+
+const testTxID = "0192a4e8-7c1e-7b3a-9f1c-3d2e4b5a6c7d"
+
+func testTransaction(status, amount, currency string) *Transaction {
+	return &Transaction{
+		TransactionID:          testTxID,
+		OrderTypeCode:          "ORD",
+		OrderTypeName:          "Order",
+		TransactionTypeCode:    "TRF",
+		TransactionTypeName:    "Transfer",
+		TransactionDate:        time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		FromAccountNumber:      "111",
+		FromAccountName:        "Budi",
+		FromAccountProductName: "Tabungan",
+		ToAccountNumber:        "222",
+		ToAccountName:          "Sari",
+		ToAccountProductName:   "Giro",
+		Amount:                 decimal.RequireFromString(amount),
+		Status:                 status,
+		Method:                 "TRANSFER",
+		Currency:               currency,
+		CreatedAt:              time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func testCreateRequest(t *Transaction) CreateRequest {
+	return CreateRequest{
+		TransactionID:          t.TransactionID,
+		NoRef:                  t.NoRef,
+		OrderTypeCode:          t.OrderTypeCode,
+		OrderTypeName:          t.OrderTypeName,
+		TransactionTypeCode:    t.TransactionTypeCode,
+		TransactionTypeName:    t.TransactionTypeName,
+		TransactionDate:        t.TransactionDate,
+		FromAccountNumber:      t.FromAccountNumber,
+		FromAccountName:        t.FromAccountName,
+		FromAccountProductName: t.FromAccountProductName,
+		ToAccountNumber:        t.ToAccountNumber,
+		ToAccountName:          t.ToAccountName,
+		ToAccountProductName:   t.ToAccountProductName,
+		Amount:                 t.Amount,
+		Status:                 t.Status,
+		Description:            t.Description,
+		Method:                 t.Method,
+		Currency:               t.Currency,
+		Metadata:               t.Metadata,
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	strp := func(s string) *string { return &s }
+	tests := []struct {
+		name       string
+		existing   *Transaction
+		in         func(CreateRequest) UpsertRequest
+		wantAction string
+		wantErr    error
+	}{
+		{"create new", nil, func(r CreateRequest) UpsertRequest {
+			return UpsertRequest{CreateRequest: r}
+		}, UpsertCreated, nil},
+		{"unchanged", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			return UpsertRequest{CreateRequest: r}
+		}, UpsertUnchanged, nil},
+		{"same time other zone", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			r.TransactionDate = r.TransactionDate.In(time.FixedZone("WIB", 7*3600))
+			return UpsertRequest{CreateRequest: r}
+		}, UpsertUnchanged, nil},
+		{"update amount", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			r.Amount = decimal.NewFromInt(125000)
+			return UpsertRequest{CreateRequest: r}
+		}, UpsertUpdated, nil},
+		{"clear description", func() *Transaction {
+			t := testTransaction(StatusSuccess, "100000", "IDR")
+			t.Description = "lama"
+			return t
+		}(), func(r CreateRequest) UpsertRequest {
+			r.Description = ""
+			return UpsertRequest{CreateRequest: r}
+		}, UpsertUpdated, nil},
+		{"illegal transition", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			r.Status = StatusPending
+			return UpsertRequest{CreateRequest: r}
+		}, "", ErrInvalidTransition},
+		{"reversed unchanged", testTransaction(StatusReversed, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			return UpsertRequest{CreateRequest: r, ParentTransactionID: strp(""), Kind: strp("")}
+		}, UpsertUnchanged, nil},
+		{"reversed via import", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			r.Status = StatusReversed
+			return UpsertRequest{CreateRequest: r}
+		}, "", ErrInvalidTransition},
+		{"new reversed", nil, func(r CreateRequest) UpsertRequest {
+			r.Status = StatusReversed
+			return UpsertRequest{CreateRequest: r}
+		}, "", ErrInvalidTransition},
+		{"new refund row", nil, func(r CreateRequest) UpsertRequest {
+			return UpsertRequest{CreateRequest: r, ParentTransactionID: strp(testTxID), Kind: strp(KindRefund)}
+		}, "", ErrReadOnlyField},
+		{"change kind", testTransaction(StatusSuccess, "100000", "IDR"), func(r CreateRequest) UpsertRequest {
+			return UpsertRequest{CreateRequest: r, Kind: strp(KindRefund)}
+		}, "", ErrReadOnlyField},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemRepo()
+			req := testCreateRequest(testTransaction(StatusSuccess, "100000", "IDR"))
+			if tt.existing != nil {
+				repo.put(tt.existing)
+				req = testCreateRequest(tt.existing)
+			}
+			svc := NewService(repo, Config{})
+			res, err := svc.Upsert(context.Background(), tt.in(req))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Action != tt.wantAction {
+				t.Fatalf("action = %s, want %s", res.Action, tt.wantAction)
+			}
+			stored := repo.rows[res.Transaction.TransactionID]
+			if res.Action == UpsertUnchanged && stored.Version != 1 {
+				t.Fatalf("unchanged row was written (version %d)", stored.Version)
+			}
+			if res.Action == UpsertUpdated && (len(res.Changes) == 0 || stored.Version != 2) {
+				t.Fatalf("changes = %v, version = %d", res.Changes, stored.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package transaction
+
+import "fmt"
+
+const (
+	StatusPending  = "PENDING"
+	StatusSuccess  = "SUCCESS"
+	StatusFailed   = "FAILED"
+	StatusReversed = "REVERSED"
+)
+
+// transitions: state machine status transaksi. FAILED & REVERSED final.
+var transitions = map[string][]string{
+	StatusPending: {StatusSuccess, StatusFailed},
+	StatusSuccess: {StatusReversed},
+}
+
+// CanTransition melaporkan apakah status boleh berubah dari from ke to.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTransition(from, to string) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
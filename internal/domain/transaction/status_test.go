@@ -0,0 +1,70 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	all := []string{StatusPending, StatusSuccess, StatusFailed, StatusReversed}
+	allowed := map[[2]string]bool{
+		{StatusPending, StatusSuccess}:  true,
+		{StatusPending, StatusFailed}:   true,
+		{StatusSuccess, StatusReversed}: true,
+	}
+	for _, from := range all {
+		for _, to := range all {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+			if err := checkTransition(from, to); (err == nil) != want || (err != nil && !errors.Is(err, ErrInvalidTransition)) {
+				t.Errorf("checkTransition(%s, %s) = %v", from, to, err)
+			}
+		}
+	}
+}
+
+func TestServiceTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		call    func(Service) (Response, error)
+		want    string
+		wantErr error
+	}{
+		{"complete pending", StatusPending, func(s Service) (Response, error) {
+			return s.Complete(context.Background(), testTxID, TransitionRequest{})
+		}, StatusSuccess, nil},
+		{"fail pending", StatusPending, func(s Service) (Response, error) {
+			return s.Fail(context.Background(), testTxID, TransitionRequest{Reason: "timeout"})
+		}, StatusFailed, nil},
+		{"complete success", StatusSuccess, func(s Service) (Response, error) {
+			return s.Complete(context.Background(), testTxID, TransitionRequest{})
+		}, "", ErrInvalidTransition},
+		{"fail reversed", StatusReversed, func(s Service) (Response, error) {
+			return s.Fail(context.Background(), testTxID, TransitionRequest{Reason: "x"})
+		}, "", ErrInvalidTransition},
+		{"put success to pending", StatusSuccess, func(s Service) (Response, error) {
+			status := StatusPending
+			return s.Update(context.Background(), testTxID, UpdateRequest{Status: &status}, 0)
+		}, "", ErrInvalidTransition},
+		{"put pending to success", StatusPending, func(s Service) (Response, error) {
+			status := StatusSuccess
+			return s.Update(context.Background(), testTxID, UpdateRequest{Status: &status}, 0)
+		}, StatusSuccess, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(newMemRepo(testTransaction(tt.from, "100000", "IDR")), Config{})
+			res, err := tt.call(svc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && res.Status != tt.want {
+				t.Fatalf("status = %s, want %s", res.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/aronipurwanto/go-download-csv/internal/pkg/audit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderActor = "X-Actor"
+
+	// localRequestIDKey: ContextKey default middleware requestid
+	localRequestIDKey = "requestid"
+
+	maxActorLen     = 128
+	maxRequestIDLen = 64
+	anonymousActor  = "anonymous"
+)
+
+// AuditInfo membaca actor (header X-Actor) dan request ID (middleware
+// requestid / header X-Request-ID) untuk dicatat di history.
+func AuditInfo(c *fiber.Ctx) audit.Info {
+	actor := c.Get(HeaderActor)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	reqID, _ := c.Locals(localRequestIDKey).(string)
+	if reqID == "" {
+		reqID = c.Get(fiber.HeaderXRequestID)
+	}
+	return audit.Info{Actor: truncate(actor, maxActorLen), RequestID: truncate(reqID, maxRequestIDLen)}
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
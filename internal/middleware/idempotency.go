@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotencyReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+	idempotencyTimeout   = 5 * time.Second
+)
+
+// replayHeaders: header response handler yang ikut disimpan & di-replay
+// (selain Content-Type), ditambah semua header berawalan replayHeaderPrefix.
+var replayHeaders = []string{
+	fiber.HeaderETag,
+	fiber.HeaderLocation,
+	fiber.HeaderContentDisposition,
+	fiber.HeaderContentEncoding,
+	fiber.HeaderCacheControl,
+}
+
+const replayHeaderPrefix = "X-Import-"
+
+// Idempotency menangani header Idempotency-Key pada request yang mengubah
+// data. Tanpa header, request diteruskan apa adanya. Response (< 500)
+// disimpan dan di-replay untuk retry dengan key & request yang sama; key
+// yang sama dengan request berbeda => 422; duplikat yang masih diproses => 409.
+// handlerTimeout = batas waktu handler di belakangnya: key dikunci minimal
+// selama itu (plus waktu simpan), jadi retry tidak mengambil alih key saat
+// request pertama masih berjalan.
+func Idempotency(svc idempotency.Service, handlerTimeout time.Duration) fiber.Handler {
+	lock := handlerTimeout + 2*idempotencyTimeout
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get(HeaderIdempotencyKey))
+		if key == "" || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_idempotency_key", "detail": "Idempotency-Key must be at most 255 characters"})
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body", "detail": err.Error()})
+		}
+		ctx, cancel := context.WithTimeout(c.Context(), idempotencyTimeout)
+		defer cancel()
+		rec, err := svc.Begin(ctx, key, fingerprint, lock)
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "idempotency_key_mismatch", "detail": err.Error()})
+		case errors.Is(err, idempotency.ErrInProgress):
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "idempotency_key_in_progress", "detail": err.Error()})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "idempotency_unavailable", "detail": err.Error()})
+		}
+		if rec != nil {
+			MarkEnveloped(c) // body tersimpan sudah berupa envelope
+			c.Set(HeaderIdempotencyReplayed, "true")
+			res := rec.Response()
+			for k, v := range res.Headers {
+				c.Set(k, v)
+			}
+			c.Set(fiber.HeaderContentType, res.ContentType)
+			return c.Status(res.StatusCode).Send(res.Body)
+		}
+
+		// handler boleh berjalan lama; simpan hasil dengan context baru
+		err = c.Next()
+		saveCtx, saveCancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+		defer saveCancel()
+		status := c.Response().StatusCode()
+		if err != nil || status >= fiber.StatusInternalServerError {
+			// gagal di sisi server: key dilepas supaya retry diproses ulang
+			if rerr := svc.Release(saveCtx, key); rerr != nil {
+				log.Printf("idempotency: release %q: %v", key, rerr)
+			}
+			return err
+		}
+		res := idempotency.Response{
+			StatusCode:  status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Headers:     responseHeaders(c),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if cerr := svc.Complete(saveCtx, key, res); cerr != nil {
+			log.Printf("idempotency: complete %q: %v", key, cerr)
+		}
+		return nil
+	}
+}
+
+// responseHeaders: header dari replayHeaders / replayHeaderPrefix yang diset handler.
+func responseHeaders(c *fiber.Ctx) map[string]string {
+	out := map[string]string{}
+	for _, name := range replayHeaders {
+		if v := c.Response().Header.Peek(name); len(v) > 0 {
+			out[name] = string(v)
+		}
+	}
+	c.Response().Header.VisitAll(func(k, v []byte) {
+		if name := string(k); len(name) >= len(replayHeaderPrefix) && strings.EqualFold(name[:len(replayHeaderPrefix)], replayHeaderPrefix) {
+			out[name] = string(v)
+		}
+	})
+	return out
+}
+
+// requestFingerprint: sha256 dari method, URL (termasuk query) dan body.
+// Body multipart memuat boundary acak per request, jadi yang di-hash adalah
+// field form dan isi file-nya (urut nama field).
+func requestFingerprint(c *fiber.Ctx) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(c.OriginalURL()))
+	h.Write([]byte{'\n'})
+	if !strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEMultipartForm) {
+		h.Write(c.Body())
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range sortedKeys(form.Value) {
+		for _, v := range form.Value[name] {
+			fmt.Fprintf(h, "value %q %d\n", name, len(v))
+			h.Write([]byte(v))
+		}
+	}
+	for _, name := range sortedKeys(form.File) {
+		for _, fh := range form.File[name] {
+			fmt.Fprintf(h, "file %q %d\n", name, fh.Size)
+			f, err := fh.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
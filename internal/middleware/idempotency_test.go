@@ -0,0 +1,357 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aronipurwanto/go-download-csv/internal/domain/idempotency"
+	"github.com/gofiber/fiber/v2"
+)
+
+// memRepo: idempotency.Repository di memori.
+type memRepo struct {
+	mu   sync.Mutex
+	rows map[string]idempotency.Record
+}
+
+func newMemRepo() *memRepo { return &memRepo{rows: map[string]idempotency.Record{}} }
+
+func (r *memRepo) Acquire(_ context.Context, rec *idempotency.Record) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.rows[rec.Key]; ok {
+		return false, nil
+	}
+	r.rows[rec.Key] = *rec
+	return true, nil
+}
+
+func (r *memRepo) Get(_ context.Context, key string) (*idempotency.Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.rows[key]
+	if !ok {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (r *memRepo) Complete(_ context.Context, key string, res idempotency.Response, expiresAt time.Time) error {
+	headers, err := json.Marshal(res.Headers)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.rows[key]
+	rec.Status, rec.StatusCode, rec.ContentType, rec.Headers, rec.Body, rec.ExpiresAt =
+		idempotency.StatusCompleted, res.StatusCode, res.ContentType, headers, res.Body, expiresAt
+	r.rows[key] = rec
+	return nil
+}
+
+func (r *memRepo) Delete(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rows, key)
+	return nil
+}
+
+func (r *memRepo) DeleteIfExpired(_ context.Context, key string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rec, ok := r.rows[key]; ok && rec.Expired(now) {
+		delete(r.rows, key)
+	}
+	return nil
+}
+
+func (r *memRepo) DeleteExpired(context.Context, time.Time) (int64, error) { return 0, nil }
+
+type idemRequest struct {
+	key          string
+	method       string // default POST
+	url          string // default /v1/transactions
+	body         string
+	wantStatus   int
+	wantReplayed bool
+	wantHeader   map[string]string
+}
+
+func TestIdempotency(t *testing.T) {
+	longKey := strings.Repeat("k", maxIdempotencyKeyLen+1)
+	tests := []struct {
+		name      string
+		requests  []idemRequest
+		wantCalls int
+	}{
+		{"no key passes through", []idemRequest{
+			{body: "a", wantStatus: 201},
+			{body: "a", wantStatus: 201},
+		}, 2},
+		{"replay same request", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201},
+			{key: "k1", body: "a", wantStatus: 201, wantReplayed: true},
+			{key: "k1", body: "a", wantStatus: 201, wantReplayed: true},
+		}, 1},
+		{"4xx is stored", []idemRequest{
+			{key: "k1", body: "bad", wantStatus: 400},
+			{key: "k1", body: "bad", wantStatus: 400, wantReplayed: true},
+		}, 1},
+		{"5xx releases key", []idemRequest{
+			{key: "k1", body: "boom", wantStatus: 500},
+			{key: "k1", body: "boom", wantStatus: 500},
+		}, 2},
+		// bulk yang terpotong timeout (504, hasil sebagian) => retry menjalankan ulang bulk
+		{"bulk timeout releases key", []idemRequest{
+			{key: "k1", url: "/v1/transactions/bulk", body: "partial", wantStatus: 504},
+			{key: "k1", url: "/v1/transactions/bulk", body: "partial", wantStatus: 504},
+		}, 2},
+		{"replay keeps headers", []idemRequest{
+			{key: "k1", url: "/v1/transactions/import", body: "import", wantStatus: 200, wantHeader: importHeaders},
+			{key: "k1", url: "/v1/transactions/import", body: "import", wantStatus: 200, wantReplayed: true, wantHeader: importHeaders},
+		}, 1},
+		{"replay keeps etag and location", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201, wantHeader: createdHeaders},
+			{key: "k1", body: "a", wantStatus: 201, wantReplayed: true, wantHeader: createdHeaders},
+		}, 1},
+		{"same key other body", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201},
+			{key: "k1", body: "b", wantStatus: 422},
+		}, 1},
+		{"same key other query", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201},
+			{key: "k1", url: "/v1/transactions?atomic=true", body: "a", wantStatus: 422},
+		}, 1},
+		{"other keys", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201},
+			{key: "k2", body: "a", wantStatus: 201},
+		}, 2},
+		{"key is trimmed", []idemRequest{
+			{key: "k1", body: "a", wantStatus: 201},
+			{key: " k1 ", body: "a", wantStatus: 201, wantReplayed: true},
+		}, 1},
+		{"key too long", []idemRequest{
+			{key: longKey, body: "a", wantStatus: 400},
+		}, 0},
+		{"get ignores key", []idemRequest{
+			{key: "k1", method: fiber.MethodGet, wantStatus: 200},
+			{key: "k1", method: fiber.MethodGet, wantStatus: 200},
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			app := fiber.New()
+			app.Use(Idempotency(idempotency.NewService(newMemRepo(), idempotency.Config{}), time.Minute))
+			handler := func(c *fiber.Ctx) error {
+				calls++
+				switch string(c.Body()) {
+				case "bad":
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bad"})
+				case "boom":
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "boom"})
+				case "partial":
+					return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "bulk_incomplete"})
+				case "import":
+					for k, v := range importHeaders {
+						c.Set(k, v)
+					}
+					c.Set("X-Other", "x") // di luar whitelist
+					return c.SendString("Line,Error\n")
+				}
+				status := fiber.StatusOK
+				if c.Method() == fiber.MethodPost {
+					status = fiber.StatusCreated
+				}
+				for k, v := range createdHeaders {
+					c.Set(k, v)
+				}
+				return c.Status(status).JSON(fiber.Map{"call": calls})
+			}
+			app.Post("/v1/transactions", handler)
+			app.Post("/v1/transactions/bulk", handler)
+			app.Post("/v1/transactions/import", handler)
+			app.Get("/v1/transactions", handler)
+
+			var first []byte
+			for i, r := range tt.requests {
+				res := doIdem(t, app, r)
+				if res.StatusCode != r.wantStatus {
+					t.Fatalf("request %d: status = %d, want %d", i, res.StatusCode, r.wantStatus)
+				}
+				if got := res.Header.Get(HeaderIdempotencyReplayed) == "true"; got != r.wantReplayed {
+					t.Fatalf("request %d: replayed = %v, want %v", i, got, r.wantReplayed)
+				}
+				for k, v := range r.wantHeader {
+					if got := res.Header.Get(k); got != v {
+						t.Fatalf("request %d: %s = %q, want %q", i, k, got, v)
+					}
+				}
+				if r.wantReplayed && res.Header.Get("X-Other") != "" {
+					t.Fatalf("request %d: replayed header outside whitelist", i)
+				}
+				body, _ := io.ReadAll(res.Body)
+				if i == 0 {
+					first = body
+				} else if r.wantReplayed && !bytes.Equal(body, first) {
+					t.Fatalf("request %d: replay body = %s, want %s", i, body, first)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+var (
+	createdHeaders = map[string]string{
+		fiber.HeaderETag:     `"1"`,
+		fiber.HeaderLocation: "/v1/transactions/tx-1",
+	}
+	importHeaders = map[string]string{
+		fiber.HeaderContentType:        "text/csv",
+		fiber.HeaderContentDisposition: `attachment; filename="import-report.csv"`,
+		"X-Import-Total":               "3",
+		"X-Import-Created":             "2",
+		"X-Import-Rejected":            "1",
+	}
+)
+
+func doIdem(t *testing.T, app *fiber.App, r idemRequest) *http.Response {
+	t.Helper()
+	method, url := r.method, r.url
+	if method == "" {
+		method = fiber.MethodPost
+	}
+	if url == "" {
+		url = "/v1/transactions"
+	}
+	req := httptest.NewRequest(method, url, strings.NewReader(r.body))
+	if r.key != "" {
+		req.Header.Set(HeaderIdempotencyKey, r.key)
+	}
+	res, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	repo := newMemRepo()
+	app := fiber.New()
+	app.Use(Idempotency(idempotency.NewService(repo, idempotency.Config{}), 10*time.Minute))
+	started, release := make(chan struct{}), make(chan struct{})
+	app.Post("/v1/transactions/import", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	req := idemRequest{key: "k1", url: "/v1/transactions/import", body: "a"}
+	first := make(chan int)
+	go func() {
+		r := httptest.NewRequest(fiber.MethodPost, req.url, strings.NewReader(req.body))
+		r.Header.Set(HeaderIdempotencyKey, req.key)
+		res, err := app.Test(r, -1)
+		if err != nil {
+			first <- 0
+			return
+		}
+		first <- res.StatusCode
+	}()
+	<-started
+
+	// key dikunci selama batas waktu handler (10 menit), bukan LockTimeout default
+	rec, _ := repo.Get(context.Background(), req.key)
+	if rec == nil || time.Until(rec.ExpiresAt) < 10*time.Minute {
+		t.Fatalf("lock record = %+v, want expiry >= handler timeout", rec)
+	}
+
+	res := doIdem(t, app, req)
+	if res.StatusCode != fiber.StatusConflict || res.Header.Get(fiber.HeaderRetryAfter) != "1" {
+		t.Fatalf("duplicate: status = %d, Retry-After = %q", res.StatusCode, res.Header.Get(fiber.HeaderRetryAfter))
+	}
+	close(release)
+	if status := <-first; status != fiber.StatusOK {
+		t.Fatalf("first: status = %d", status)
+	}
+	if res := doIdem(t, app, req); res.Header.Get(HeaderIdempotencyReplayed) != "true" {
+		t.Fatalf("after completion: status = %d, not replayed", res.StatusCode)
+	}
+}
+
+func TestRequestFingerprintMultipart(t *testing.T) {
+	form := func(fields map[string]string, file string) (string, []byte) {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b) // boundary acak per request
+		for k, v := range fields {
+			_ = w.WriteField(k, v)
+		}
+		fw, _ := w.CreateFormFile("file", "transactions.csv")
+		_, _ = fw.Write([]byte(file))
+		_ = w.Close()
+		return w.FormDataContentType(), b.Bytes()
+	}
+	tests := []struct {
+		name   string
+		fields map[string]string
+		file   string
+		same   bool
+	}{
+		{"same content new boundary", map[string]string{"note": "x"}, "a,b\n1,2\n", true},
+		{"other file content", map[string]string{"note": "x"}, "a,b\n1,3\n", false},
+		{"other field value", map[string]string{"note": "y"}, "a,b\n1,2\n", false},
+		{"extra field", map[string]string{"note": "x", "mode": "dry"}, "a,b\n1,2\n", false},
+	}
+
+	var got []string
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		fp, err := requestFingerprint(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		// form tetap bisa dibaca handler setelah fingerprint
+		if _, err := c.FormFile("file"); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		got = append(got, fp)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	send := func(ct string, body []byte) {
+		req := httptest.NewRequest(fiber.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, ct)
+		res, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != fiber.StatusOK {
+			b, _ := io.ReadAll(res.Body)
+			t.Fatalf("status = %d, body = %s", res.StatusCode, b)
+		}
+	}
+
+	send(form(map[string]string{"note": "x"}, "a,b\n1,2\n"))
+	base := got[0]
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send(form(tt.fields, tt.file))
+			if same := got[len(got)-1] == base; same != tt.same {
+				t.Fatalf("fingerprint equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+}
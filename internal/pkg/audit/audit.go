@@ -0,0 +1,22 @@
+package audit
+
+import "context"
+
+// Info: siapa & request mana yang melakukan perubahan, dibawa lewat context
+// dari layer HTTP sampai repository (transaction_history).
+type Info struct {
+	Actor     string
+	RequestID string
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext: Info kosong jika tidak ada (mis. dipanggil dari worker).
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
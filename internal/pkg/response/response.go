@@ -26,3 +26,9 @@ func Error(c *fiber.Ctx, code int, msg string) error {
 	middleware.MarkEnveloped(c)
 	return c.Status(code).JSON(Envelope{Success: false, Message: msg})
 }
+
+// ErrorWithData: seperti Error, dengan detail tambahan di data.
+func ErrorWithData(c *fiber.Ctx, code int, msg string, data interface{}) error {
+	middleware.MarkEnveloped(c)
+	return c.Status(code).JSON(Envelope{Success: false, Message: msg, Data: data})
+}